@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+
+	"go-demo/config"
+	"go-demo/database"
+	"go-demo/database/drift"
+	"go-demo/pkg/logger"
+	"go-demo/repositories"
+
+	"gorm.io/gorm"
+)
+
+// App holds what the api and worker binaries share: the database handle
+// and the repositories built on it. Components get their dependencies from
+// an App instead of reaching for package state.
+type App struct {
+	DB    *gorm.DB
+	Store repositories.Store
+}
+
+// New connects to the database cfg describes, checks its schema against
+// the models and builds the GORM-backed repositories on it.
+func New(cfg config.DB) *App {
+	db := database.Connect(cfg)
+	checkSchema(db, cfg.SchemaCheck)
+	return &App{DB: db, Store: repositories.NewGormStore(db)}
+}
+
+// Close closes the database pool. Call it last, once nothing uses the
+// store any more.
+func (a *App) Close() {
+	sqlDB, err := a.DB.DB()
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("failed to get underlying sql.DB from Gorm")
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		logger.Log.Error().Err(err).Msg("failed to close database")
+		return
+	}
+	logger.Log.Info().Msg("database connection closed")
+}
+
+// checkSchema compares the models with the live schema as mode says:
+// "warn" logs each difference, "fail" also exits, and "off" skips the
+// check.
+func checkSchema(db *gorm.DB, mode string) {
+	if mode == "off" {
+		return
+	}
+
+	drifts, err := drift.Check(context.Background(), db, drift.Models...)
+	if err != nil {
+		if mode == "fail" {
+			logger.Log.Fatal().Err(err).Msg("failed to check schema drift")
+		}
+		logger.Log.Warn().Err(err).Msg("failed to check schema drift")
+		return
+	}
+	for _, d := range drifts {
+		logger.Log.Warn().Str("table", d.Table).Str("kind", string(d.Kind)).Msg(d.String())
+	}
+	if len(drifts) > 0 && mode == "fail" {
+		logger.Log.Fatal().Int("drifts", len(drifts)).Msg("database schema does not match the models; run `go run ./cmd/migrate drift`")
+	}
+}
@@ -1,44 +1,131 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
+	"go-demo/app"
 	"go-demo/config"
-	"go-demo/database"
 	apphandlers "go-demo/handlers"
 	"go-demo/middlewares"
+	"go-demo/pkg/apiversion"
 	"go-demo/pkg/logger"
 	"go-demo/pkg/validator"
 
 	ghandlers "github.com/gorilla/handlers"
 )
 
+// unversionedDeprecated is when the paths without a /v1 prefix were
+// deprecated.
+var unversionedDeprecated = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
 func main() {
-	config.LoadEnv()
-	logger.Init()
+	cfg, _, err := config.Load(os.Args[1:])
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("invalid configuration")
+	}
+	logger.Init(cfg.LogLevel)
+	logger.Log.Debug().Interface("config", cfg.Redacted()).Msg("configuration loaded")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// SIGHUP or POST /admin/config/reload re-reads the configuration; the
+	// log level, rate limits and CORS origins follow it without a restart
+	live := config.NewLive(cfg, os.Args[1:])
+	live.OnReload(func(c config.Config) { logger.SetLevel(c.LogLevel) })
+	live.WatchSIGHUP(ctx)
+
 	// initialize validator before connecting/handling requests
 	validator.Init()
 
-	database.Connect()
+	a := app.New(cfg.DB)
+	server := apphandlers.NewServer(a.Store)
+	server.RequireIfMatch = cfg.HTTP.RequireIfMatch
+	server.AdminToken = cfg.HTTP.AdminToken
+	// outside production, also check responses against the OpenAPI contract
+	validateResponses := false
+	switch cfg.Env {
+	case "dev", "development", "test":
+		validateResponses = true
+	}
 
-	mux := http.NewServeMux()
+	v1 := http.NewServeMux()
+	server.RegisterRoutes(v1)
+	v1Handler := middlewares.OpenAPIValidationMiddleware(apphandlers.Spec(), validateResponses, v1)
 
-	mux.HandleFunc("/users", apphandlers.UserHandler)
-	mux.HandleFunc("/products", apphandlers.ProductHandler)
+	// Versions are mounted side by side; a /v2 mux is mounted the same way
+	// once it exists. The unversioned paths remain as a deprecated alias of
+	// v1 until clients have moved over.
+	mux := http.NewServeMux()
+	versions := apiversion.NewRouter(mux)
+	versions.Mount(apiversion.Version{Name: apphandlers.APIVersion, Handler: v1Handler})
+	versions.Mount(apiversion.Version{
+		Handler:    v1Handler,
+		Deprecated: unversionedDeprecated,
+		Sunset:     cfg.HTTP.UnversionedSunset,
+		Successor:  apphandlers.APIVersion,
+	})
+	// per-instance counts since start, not a deployment-wide total
+	mux.Handle("GET /admin/api-versions", server.AdminHandler(versions.StatsHandler()))
+	mux.Handle("POST /admin/config/reload", server.AdminHandler(live.ReloadHandler()))
 
 	// Build handler chain:
-	// 1) base mux
-	// 2) logging middleware
-	// 3) rate limiting
-	// 4) compression
-	// 5) CORS
-	// 6) recovery (outermost)
-	handler := middlewares.LoggingMiddleware(mux)
-	handler = middlewares.RateLimitMiddleware(handler)
+	// 1) versioned mux, each version with OpenAPI request (and, outside
+	//    production, response) validation
+	// 2) idempotency keys for POST retries
+	// 3) logging middleware
+	// 4) rate limiting
+	// 5) compression
+	// 6) CORS
+	// 7) recovery (outermost)
+	handler := middlewares.IdempotencyMiddleware(a.Store.Idempotency, mux)
+	handler = middlewares.LoggingMiddleware(handler)
+	handler = middlewares.RateLimitMiddleware(func() config.RateLimit { return live.Get().RateLimit }, handler)
 	handler = ghandlers.CompressHandler(handler)
-	handler = ghandlers.CORS(ghandlers.AllowedOrigins([]string{"*"}))(handler)
+	handler = ghandlers.CORS(
+		ghandlers.AllowedOriginValidator(func(origin string) bool {
+			origins := live.Get().HTTP.CORSOrigins
+			return slices.Contains(origins, "*") || slices.Contains(origins, origin)
+		}),
+		ghandlers.AllowedHeaders([]string{"Authorization", "Content-Type", "If-Match", "If-None-Match", "Idempotency-Key"}),
+		ghandlers.ExposedHeaders([]string{"Location", "ETag", "Idempotent-Replayed", "Deprecation", "Sunset", "Link"}),
+	)(handler)
 	handler = ghandlers.RecoveryHandler(ghandlers.PrintRecoveryStack(true))(handler)
 
-	logger.Log.Info().Msg("🚀 Server running on :8080")
-	http.ListenAndServe(":8080", handler)
+	srv := &http.Server{Addr: cfg.HTTP.Addr, Handler: handler}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Log.Info().Msg("🚀 Server running on " + cfg.HTTP.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		logger.Log.Fatal().Err(err).Msg("server failed")
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Stop accepting connections and drain in-flight requests; the database
+	// closes only once no handler can use it
+	logger.Log.Info().Msg("shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Error().Err(err).Msg("server did not drain in time")
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.Error().Err(err).Msg("server failed")
+	}
+
+	a.Close()
+	logger.Log.Info().Msg("server stopped")
 }
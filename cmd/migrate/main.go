@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go-demo/config"
+	"go-demo/database"
+	"go-demo/database/drift"
+	"go-demo/database/migrations"
+	"go-demo/pkg/logger"
+)
+
+const usage = `usage: migrate [flags] <command>
+
+commands:
+  up        apply all pending migrations
+  down N    roll back the N most recently applied migrations
+  status    list migrations and whether they are applied
+  redo      roll back the latest migration and apply it again
+  drift     compare the models with the live schema; exits 1 on drift
+
+flags are the configuration flags shared with the api and worker, e.g.
+-db-driver=sqlite -db-path=go-demo.db`
+
+func main() {
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("invalid configuration")
+	}
+	logger.Init(cfg.LogLevel)
+
+	if len(args) == 0 {
+		fail(usage)
+	}
+
+	db := database.Connect(cfg.DB)
+	m, err := migrations.New(db)
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("failed to load migrations")
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			logger.Log.Fatal().Err(err).Msg("migrate up failed")
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		for _, mig := range applied {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+	case "down":
+		if len(args) != 2 {
+			fail("usage: migrate down N")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fail("migrate down: N must be a positive number")
+		}
+		reverted, err := m.Down(ctx, n)
+		if err != nil {
+			logger.Log.Fatal().Err(err).Msg("migrate down failed")
+		}
+		for _, mig := range reverted {
+			fmt.Printf("rolled back %04d_%s\n", mig.Version, mig.Name)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			logger.Log.Fatal().Err(err).Msg("migrate status failed")
+		}
+		printStatus(statuses)
+	case "redo":
+		mig, err := m.Redo(ctx)
+		if err != nil {
+			logger.Log.Fatal().Err(err).Msg("migrate redo failed")
+		}
+		fmt.Printf("redone %04d_%s\n", mig.Version, mig.Name)
+	case "drift":
+		drifts, err := drift.Check(ctx, db, drift.Models...)
+		if err != nil {
+			logger.Log.Fatal().Err(err).Msg("drift check failed")
+		}
+		if len(drifts) == 0 {
+			fmt.Println("schema matches the models")
+			return
+		}
+		for _, d := range drifts {
+			fmt.Println(d)
+		}
+		os.Exit(1)
+	default:
+		fail(usage)
+	}
+}
+
+func printStatus(statuses []migrations.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", ""
+		if s.AppliedAt != nil {
+			state, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		switch {
+		case s.Missing:
+			state = "applied, no script"
+		case s.Modified:
+			state = "applied, script changed"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
+
+func fail(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(2)
+}
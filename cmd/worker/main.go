@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go-demo/app"
 	"go-demo/config"
-	"go-demo/database"
 	"go-demo/pkg/logger"
 	"go-demo/pkg/validator"
 	"go-demo/worker"
@@ -11,28 +17,48 @@ import (
 )
 
 func main() {
-	config.LoadEnv()
-	logger.Init()
+	cfg, _, err := config.Load(os.Args[1:])
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("invalid configuration")
+	}
+	logger.Init(cfg.LogLevel)
+	logger.Log.Debug().Interface("config", cfg.Redacted()).Msg("configuration loaded")
 	// initialize validator in case workers need it for data validation
 	validator.Init()
 
-	database.Connect()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a := app.New(cfg.DB)
+	workers := worker.New(a.DB, a.Store, cfg.Worker)
+
+	// SIGHUP re-reads the configuration; the log level, poll interval and
+	// cleanup retention and schedule follow it without a restart
+	live := config.NewLive(cfg, os.Args[1:])
+	live.OnReload(func(c config.Config) {
+		logger.SetLevel(c.LogLevel)
+		workers.Reconfigure(c.Worker)
+	})
+	live.WatchSIGHUP(ctx)
 
 	logger.Log.Info().Msg("Starting background workers...")
 
 	// start the background audit worker
 	// This starts a goroutine that listens on a channel (or DB polling in v2)
-	worker.StartWorker()
+	workers.StartAuditWorker(ctx)
 
 	// start the background notification worker
 	// This also starts a goroutine
-	worker.StartNotificationWorker()
+	workers.StartNotificationWorker(ctx)
 
 	// Initialize Cron Scheduler
 	c := cron.New()
 
 	// Register the cleanup worker
-	worker.RegisterCleanupWorker(c)
+	workers.RegisterCleanupWorker(c)
+
+	// Register the expired idempotency key cleanup
+	workers.RegisterIdempotencyCleanup(c)
 
 	// Start the cron scheduler (runs in its own goroutine)
 	c.Start()
@@ -40,6 +66,29 @@ func main() {
 
 	logger.Log.Info().Msg("Background workers started successfully")
 
-	// Block forever to keep the workers running
-	select {}
+	// Run until signalled, then stop polling and scheduling and let the
+	// current batches and cron jobs finish before the database goes away
+	<-ctx.Done()
+	stop()
+	logger.Log.Info().Msg("shutting down workers...")
+
+	cronDone := c.Stop()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		<-cronDone.Done()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Log.Info().Msg("workers stopped")
+	case <-time.After(cfg.Worker.ShutdownTimeout):
+		// A cron job may be cut off, but a poll batch is at most 100 rows
+		// and closing the database under it would strand them PROCESSING
+		logger.Log.Warn().Dur("timeout", cfg.Worker.ShutdownTimeout).
+			Msg("workers did not stop in time; waiting for the current poll batches")
+		workers.Wait()
+	}
+
+	a.Close()
 }
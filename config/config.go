@@ -1,36 +1,158 @@
+// Package config holds the typed configuration of the api, worker and
+// migrate binaries. Load builds it in layers: Defaults, then a dotenv
+// file, then the environment, then command-line flags, each overriding the
+// one before.
 package config
 
 import (
-	"os"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"time"
 
-	"go-demo/pkg/logger"
-
-	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
 )
 
-func LoadEnv() {
-	root, _ := os.Getwd()
+// Config is the whole configuration. Each setting is named by its env tag,
+// which is also its key in the config file; its flag is the same name in
+// lower case with dashes, e.g. -db-host for DB_HOST. Settings tagged
+// reload change on Live.Reload; the rest need a restart.
+type Config struct {
+	Env      string `env:"APP_ENV"`
+	LogLevel string `env:"LOG_LEVEL" reload:"true"`
+
+	HTTP      HTTP
+	RateLimit RateLimit
+	DB        DB
+	Worker    Worker
+}
+
+// HTTP configures the API server.
+type HTTP struct {
+	Addr            string        `env:"HTTP_ADDR"`
+	ShutdownTimeout time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT"` // drain time for in-flight requests
+	CORSOrigins     []string      `env:"CORS_ORIGINS" reload:"true"`
+	// RequireIfMatch rejects unconditional PUT/PATCH/DELETE with 428.
+	RequireIfMatch bool `env:"REQUIRE_IF_MATCH"`
+	// AdminToken enables the admin routes for bearer requests; empty
+	// disables them.
+	AdminToken string `env:"ADMIN_TOKEN" secret:"true"`
+	// UnversionedSunset, when set, is announced as the Sunset of the
+	// deprecated paths without a version prefix.
+	UnversionedSunset time.Time `env:"UNVERSIONED_SUNSET"`
+}
+
+// RateLimit configures the per-IP token bucket.
+type RateLimit struct {
+	RPS   float64 `env:"RATE_LIMIT_RPS" reload:"true"`
+	Burst int     `env:"RATE_LIMIT_BURST" reload:"true"`
+}
+
+// DB selects and configures the database.
+type DB struct {
+	Driver   string `env:"DB_DRIVER"` // postgres or sqlite
+	Host     string `env:"DB_HOST"`
+	Port     string `env:"DB_PORT"`
+	User     string `env:"DB_USER"`
+	Password string `env:"DB_PASSWORD" secret:"true"`
+	Name     string `env:"DB_NAME"`
+	SSLMode  string `env:"DB_SSLMODE"`
+	Path     string `env:"DB_PATH"` // SQLite file
+	// SchemaCheck is what to do when the schema drifts from the models at
+	// startup: off, warn or fail.
+	SchemaCheck string `env:"SCHEMA_CHECK"`
+}
+
+// Worker configures the background pollers and cron cleanups.
+type Worker struct {
+	PollInterval    time.Duration `env:"WORKER_POLL_INTERVAL" reload:"true"`
+	ShutdownTimeout time.Duration `env:"WORKER_SHUTDOWN_TIMEOUT"` // time to finish cron jobs; poll batches always finish
+	CleanupSchedule string        `env:"CLEANUP_SCHEDULE" reload:"true"`
+	// Retention is the age after which users and products are moved to
+	// the trash.
+	Retention time.Duration `env:"CLEANUP_RETENTION" reload:"true"`
+	// TrashGrace is how long trashed records stay restorable before they
+	// are removed permanently.
+	TrashGrace                 time.Duration `env:"CLEANUP_TRASH_GRACE"`
+	IdempotencyCleanupSchedule string        `env:"IDEMPOTENCY_CLEANUP_SCHEDULE"`
+}
 
-	// Move to project root if running from tests/
-	if filepath.Base(root) == "tests" {
-		root = filepath.Dir(root)
+// Defaults returns the configuration used where no layer sets a value.
+func Defaults() Config {
+	return Config{
+		LogLevel: "info",
+		HTTP: HTTP{
+			Addr:            ":8080",
+			ShutdownTimeout: 15 * time.Second,
+			CORSOrigins:     []string{"*"},
+		},
+		RateLimit: RateLimit{RPS: 5, Burst: 10},
+		DB: DB{
+			Driver:      "postgres",
+			Port:        "5432",
+			Path:        "go-demo.db",
+			SchemaCheck: "warn",
+		},
+		Worker: Worker{
+			PollInterval:               1 * time.Second,
+			ShutdownTimeout:            30 * time.Second,
+			CleanupSchedule:            "@every 1m",
+			Retention:                  1 * time.Minute,
+			TrashGrace:                 7 * 24 * time.Hour,
+			IdempotencyCleanupSchedule: "@every 1h",
+		},
 	}
+}
 
-	env := os.Getenv("APP_ENV")
+// Validate reports every invalid setting at once, each error naming the
+// setting's key.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+		}
+	}
+	schedule := func(key, spec string) {
+		if _, err := cron.ParseStandard(spec); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid cron schedule %q: %w", key, spec, err))
+		}
+	}
 
-	var envFile string
-	if env == "test" {
-		envFile = filepath.Join(root, ".env.test")
-		logger.Log.Info().Msg("loading test environment")
-	} else {
-		envFile = filepath.Join(root, ".env")
-		logger.Log.Info().Msg("loading production environment")
+	switch c.LogLevel {
+	case "debug", "info", "warn", "warning", "error", "fatal", "panic":
+	default:
+		check(false, "LOG_LEVEL", "must be one of debug, info, warn, error, fatal or panic, got %q", c.LogLevel)
 	}
 
-	logger.Log.Info().Str("envFile", envFile).Msg("loading env file")
+	check(c.HTTP.Addr != "", "HTTP_ADDR", "must not be empty")
+	check(c.HTTP.ShutdownTimeout > 0, "HTTP_SHUTDOWN_TIMEOUT", "must be positive")
+	check(len(c.HTTP.CORSOrigins) > 0, "CORS_ORIGINS", "must list at least one origin")
 
-	if err := godotenv.Load(envFile); err != nil {
-		logger.Log.Fatal().Err(err).Str("envFile", envFile).Msg("failed to load env file")
+	check(c.RateLimit.RPS > 0, "RATE_LIMIT_RPS", "must be positive")
+	check(c.RateLimit.Burst >= 1, "RATE_LIMIT_BURST", "must be at least 1")
+
+	switch c.DB.Driver {
+	case "postgres":
+		check(c.DB.Host != "", "DB_HOST", "is required for the postgres driver")
+		check(c.DB.User != "", "DB_USER", "is required for the postgres driver")
+		check(c.DB.Name != "", "DB_NAME", "is required for the postgres driver")
+	case "sqlite":
+		check(c.DB.Path != "", "DB_PATH", "is required for the sqlite driver")
+	default:
+		check(false, "DB_DRIVER", "must be postgres or sqlite, got %q", c.DB.Driver)
+	}
+	switch c.DB.SchemaCheck {
+	case "off", "warn", "fail":
+	default:
+		check(false, "SCHEMA_CHECK", "must be off, warn or fail, got %q", c.DB.SchemaCheck)
 	}
+
+	check(c.Worker.PollInterval > 0, "WORKER_POLL_INTERVAL", "must be positive")
+	check(c.Worker.ShutdownTimeout > 0, "WORKER_SHUTDOWN_TIMEOUT", "must be positive")
+	check(c.Worker.Retention > 0, "CLEANUP_RETENTION", "must be positive")
+	check(c.Worker.TrashGrace > 0, "CLEANUP_TRASH_GRACE", "must be positive")
+	schedule("CLEANUP_SCHEDULE", c.Worker.CleanupSchedule)
+	schedule("IDEMPOTENCY_CLEANUP_SCHEDULE", c.Worker.IdempotencyCleanupSchedule)
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"go-demo/pkg/cmputil"
+	"go-demo/pkg/logger"
+	"go-demo/pkg/problem"
+)
+
+// Live holds the running configuration and replaces its reloadable
+// settings on Reload. Readers call Get for the current value; components
+// that keep state derived from it register with OnReload.
+type Live struct {
+	args []string
+	cur  atomic.Pointer[Config]
+
+	mu        sync.Mutex // serialises reloads and guards listeners
+	listeners []func(Config)
+}
+
+// NewLive returns a Live starting at cfg. Reloads call Load with args, so
+// the flags given at startup keep their precedence.
+func NewLive(cfg Config, args []string) *Live {
+	l := &Live{args: args}
+	l.cur.Store(&cfg)
+	return l
+}
+
+// Get returns the current configuration.
+func (l *Live) Get() Config {
+	return *l.cur.Load()
+}
+
+// OnReload registers fn to be called with the new configuration after
+// each reload that changes a setting.
+func (l *Live) OnReload(fn func(Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listeners = append(l.listeners, fn)
+}
+
+// Reload loads the configuration again and swaps in the settings tagged
+// reload. It returns the diff of what changed, empty when nothing did.
+// An invalid configuration is rejected as a whole and the current one
+// kept. Changes to other settings are logged and left for a restart.
+func (l *Live) Reload() (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	loaded, _, err := Load(l.args)
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("configuration reload rejected")
+		return "", err
+	}
+
+	old := l.Get()
+	next := old
+	live, fresh := settings(reflect.ValueOf(&next).Elem()), settings(reflect.ValueOf(&loaded).Elem())
+	for i, s := range live {
+		if s.reload {
+			s.value.Set(fresh[i].value)
+		}
+	}
+	if diff := diffSettings(next, loaded, false); diff != "" {
+		logger.Log.Warn().Str("diff", diff).Msg("configuration changes need a restart to apply")
+	}
+
+	diff := diffSettings(old, next, true)
+	if diff == "" {
+		logger.Log.Info().Msg("configuration reloaded; nothing changed")
+		return "", nil
+	}
+	// logged before the listeners run, as one may raise the log level
+	logger.Log.Info().Str("diff", diff).Msg("configuration reloaded")
+	l.cur.Store(&next)
+	for _, fn := range l.listeners {
+		fn(next)
+	}
+	return diff, nil
+}
+
+// diffSettings diffs the settings of a and b that a reload applies, or
+// those that it does not, by key. A secret shows only that it changed.
+func diffSettings(a, b Config, reloadable bool) string {
+	before, after := map[string]string{}, map[string]string{}
+	bs := settings(reflect.ValueOf(&b).Elem())
+	for i, s := range settings(reflect.ValueOf(&a).Elem()) {
+		if s.reload != reloadable {
+			continue
+		}
+		before[s.key], after[s.key] = s.redacted(), bs[i].redacted()
+		if s.secret && s.String() != bs[i].String() {
+			after[s.key] = "[redacted, changed]"
+		}
+	}
+	return cmputil.Diff(before, after)
+}
+
+// WatchSIGHUP reloads the configuration on every SIGHUP until ctx is
+// done. SIGHUP is ignored from then on rather than restored to its default
+// action, which would kill a process still draining, e.g. when log
+// rotation signals it during shutdown.
+func (l *Live) WatchSIGHUP(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				signal.Ignore(syscall.SIGHUP)
+				return
+			case <-hup:
+				logger.Log.Info().Msg("SIGHUP received; reloading configuration")
+				l.Reload()
+			}
+		}
+	}()
+}
+
+// ReloadHandler reloads the configuration on request and responds with
+// the diff of what changed. Mount it behind admin authentication.
+func (l *Live) ReloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		diff, err := l.Reload()
+		if err != nil {
+			problem.Error(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"changed": diff != "", "diff": diff})
+	})
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-demo/pkg/logger"
+
+	"github.com/joho/godotenv"
+)
+
+// Load builds the configuration from Defaults, the config file, the
+// environment (where empty variables count as unset) and the flags in
+// args, and validates it. It returns the arguments left after the flags.
+// Parse and validation errors are returned together, so one run reports
+// everything that needs fixing.
+//
+// The config file is a dotenv file using the same keys as the environment.
+// It is named by -config or CONFIG_FILE; without either, .env (.env.test
+// when APP_ENV is test) is read from the working directory if it exists.
+func Load(args []string) (Config, []string, error) {
+	fields := settings(reflect.ValueOf(&Config{}).Elem())
+
+	fset := flag.NewFlagSet("config", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+	file := fset.String("config", os.Getenv("CONFIG_FILE"), "dotenv file to load settings from")
+	flags := map[string]string{}
+	for _, s := range fields {
+		fset.Func(s.flag(), "overrides "+s.key, func(v string) error {
+			flags[s.key] = v
+			return nil
+		})
+	}
+	if err := fset.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	// Layers, lowest precedence first
+	values, err := readFile(*file, flags)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	// An empty variable counts as unset
+	for _, s := range fields {
+		if v := os.Getenv(s.key); v != "" {
+			values[s.key] = v
+		}
+	}
+	for k, v := range flags {
+		values[k] = v
+	}
+
+	cfg := Defaults()
+	var errs []error
+	for _, s := range settings(reflect.ValueOf(&cfg).Elem()) {
+		if v, ok := values[s.key]; ok {
+			if err := s.set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+			}
+		}
+	}
+	// A setting that failed to parse keeps its valid default, so
+	// validation adds no duplicate for it
+	if err := errors.Join(append(errs, cfg.Validate())...); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, fset.Args(), nil
+}
+
+// readFile reads the dotenv file at path. A missing file is an error only
+// when named explicitly; the default one is optional, as deployments
+// usually set real environment variables instead.
+func readFile(path string, flags map[string]string) (map[string]string, error) {
+	explicit := path != ""
+	if !explicit {
+		env := os.Getenv("APP_ENV")
+		if v, ok := flags["APP_ENV"]; ok {
+			env = v
+		}
+		path = ".env"
+		if env == "test" {
+			path = ".env.test"
+		}
+	}
+
+	values, err := godotenv.Read(path)
+	switch {
+	case err == nil:
+		logger.Log.Info().Str("configFile", path).Msg("loaded config file")
+		return values, nil
+	case !explicit && errors.Is(err, fs.ErrNotExist):
+		return map[string]string{}, nil
+	default:
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+}
+
+// Redacted returns every setting by key, with secrets that are set
+// replaced, for logging the effective configuration.
+func (c Config) Redacted() map[string]string {
+	out := map[string]string{}
+	for _, s := range settings(reflect.ValueOf(&c).Elem()) {
+		out[s.key] = s.redacted()
+	}
+	return out
+}
+
+// setting is one tagged field of Config.
+type setting struct {
+	key    string
+	secret bool
+	reload bool
+	value  reflect.Value
+}
+
+// settings lists the tagged fields of v, a Config or one of its sections.
+func settings(v reflect.Value) []setting {
+	var out []setting
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) {
+			out = append(out, settings(v.Field(i))...)
+			continue
+		}
+		if key := f.Tag.Get("env"); key != "" {
+			out = append(out, setting{
+				key:    key,
+				secret: f.Tag.Get("secret") == "true",
+				reload: f.Tag.Get("reload") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	return out
+}
+
+func (s setting) flag() string {
+	return strings.ReplaceAll(strings.ToLower(s.key), "_", "-")
+}
+
+// set parses raw into the field.
+func (s setting) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch p := s.value.Addr().Interface().(type) {
+	case *string:
+		*p = raw
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		*p = b
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*p = n
+	case *float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		*p = f
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		*p = d
+	case *time.Time:
+		if raw == "" {
+			*p = time.Time{}
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return fmt.Errorf("invalid RFC 3339 time %q", raw)
+		}
+		*p = t
+	case *[]string:
+		var list []string
+		for item := range strings.SplitSeq(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*p = list
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %s", s.value.Type()))
+	}
+	return nil
+}
+
+// redacted is String, hiding a secret that is set.
+func (s setting) redacted() string {
+	if v := s.String(); !s.secret || v == "" {
+		return v
+	}
+	return "[redacted]"
+}
+
+// String formats the field the way set parses it.
+func (s setting) String() string {
+	switch v := s.value.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,301 @@
+// Package drift compares the GORM models with the schema actually deployed,
+// so a model edited without a migration, or a migration that does not match
+// its model, is caught before it fails a query.
+package drift
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"go-demo/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Models are the models the application reads and writes, checked by
+// default.
+var Models = []any{
+	&models.User{},
+	&models.Product{},
+	&models.AuditLog{},
+	&models.NotificationOutbox{},
+	&models.IdempotencyKey{},
+}
+
+// Kind classifies a difference.
+type Kind string
+
+const (
+	MissingTable  Kind = "missing table"
+	MissingColumn Kind = "missing column"
+	ExtraColumn   Kind = "extra column"
+	TypeMismatch  Kind = "type mismatch"
+	NullMismatch  Kind = "nullability mismatch"
+	MissingIndex  Kind = "missing index"
+	ExtraIndex    Kind = "extra index"
+	IndexMismatch Kind = "index mismatch"
+)
+
+// Drift is one difference between a model and its table.
+type Drift struct {
+	Table  string
+	Column string // set for column drift
+	Index  string // set for index drift
+	Kind   Kind
+	Detail string
+}
+
+func (d Drift) String() string {
+	target := d.Table
+	switch {
+	case d.Column != "":
+		target += "." + d.Column
+	case d.Index != "":
+		target += " index " + d.Index
+	}
+	if d.Detail == "" {
+		return fmt.Sprintf("%s: %s", target, d.Kind)
+	}
+	return fmt.Sprintf("%s: %s (%s)", target, d.Kind, d.Detail)
+}
+
+// column and index are the live schema as introspected.
+type column struct {
+	Table, Name, Type string
+	NotNull           bool
+}
+
+type index struct {
+	Table, Name string
+	Unique      bool
+	Columns     []string
+}
+
+// Check compares each of models with its table in db and returns the
+// differences, ordered by table.
+func Check(ctx context.Context, db *gorm.DB, models ...any) ([]Drift, error) {
+	db = db.WithContext(ctx)
+	schemas := make([]*schema.Schema, 0, len(models))
+	tables := make([]string, 0, len(models))
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			return nil, fmt.Errorf("parse model %T: %w", m, err)
+		}
+		schemas = append(schemas, stmt.Schema)
+		tables = append(tables, stmt.Schema.Table)
+	}
+
+	var (
+		columns []column
+		indexes []index
+		err     error
+	)
+	switch dialect := db.Dialector.Name(); dialect {
+	case "postgres":
+		columns, indexes, err = introspectPostgres(db, tables)
+	case "sqlite":
+		columns, indexes, err = introspectSQLite(db, tables)
+	default:
+		return nil, fmt.Errorf("drift check does not support dialect %q", dialect)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []Drift
+	for _, s := range schemas {
+		drifts = append(drifts, compare(db.Dialector.Name(), s, columns, indexes)...)
+	}
+	return drifts, nil
+}
+
+// compare diffs one model against the live columns and indexes.
+func compare(dialect string, s *schema.Schema, columns []column, indexes []index) []Drift {
+	live := map[string]column{}
+	for _, c := range columns {
+		if c.Table == s.Table {
+			live[c.Name] = c
+		}
+	}
+	if len(live) == 0 {
+		return []Drift{{Table: s.Table, Kind: MissingTable}}
+	}
+
+	var drifts []Drift
+	for _, f := range s.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		c, ok := live[f.DBName]
+		if !ok {
+			drifts = append(drifts, Drift{Table: s.Table, Column: f.DBName, Kind: MissingColumn})
+			continue
+		}
+		delete(live, f.DBName)
+
+		if want, ok := typeMatches(dialect, f, c.Type); !ok {
+			drifts = append(drifts, Drift{Table: s.Table, Column: f.DBName, Kind: TypeMismatch,
+				Detail: fmt.Sprintf("database has %s, model expects %s", c.Type, want)})
+		}
+		switch notNull := f.NotNull || f.PrimaryKey; {
+		case notNull && !c.NotNull:
+			drifts = append(drifts, Drift{Table: s.Table, Column: f.DBName, Kind: NullMismatch,
+				Detail: "model is NOT NULL, database allows NULL"})
+		case !notNull && c.NotNull && writesNull(f):
+			drifts = append(drifts, Drift{Table: s.Table, Column: f.DBName, Kind: NullMismatch,
+				Detail: "database is NOT NULL, model can write NULL"})
+		}
+	}
+	for _, name := range sortedKeys(live) {
+		drifts = append(drifts, Drift{Table: s.Table, Column: name, Kind: ExtraColumn})
+	}
+
+	return append(drifts, compareIndexes(s, indexes)...)
+}
+
+func compareIndexes(s *schema.Schema, indexes []index) []Drift {
+	live := map[string]index{}
+	for _, idx := range indexes {
+		if idx.Table == s.Table {
+			live[idx.Name] = idx
+		}
+	}
+
+	var drifts []Drift
+	for _, want := range s.ParseIndexes() {
+		cols := make([]string, 0, len(want.Fields))
+		for _, f := range want.Fields {
+			cols = append(cols, f.DBName)
+		}
+		unique := want.Class == "UNIQUE"
+
+		got, ok := live[want.Name]
+		if !ok {
+			drifts = append(drifts, Drift{Table: s.Table, Index: want.Name, Kind: MissingIndex,
+				Detail: describeIndex(unique, cols)})
+			continue
+		}
+		delete(live, want.Name)
+		if got.Unique != unique || !slices.Equal(got.Columns, cols) {
+			drifts = append(drifts, Drift{Table: s.Table, Index: want.Name, Kind: IndexMismatch,
+				Detail: fmt.Sprintf("database has %s, model expects %s", describeIndex(got.Unique, got.Columns), describeIndex(unique, cols))})
+		}
+	}
+	for _, name := range sortedKeys(live) {
+		drifts = append(drifts, Drift{Table: s.Table, Index: name, Kind: ExtraIndex,
+			Detail: describeIndex(live[name].Unique, live[name].Columns)})
+	}
+	return drifts
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func describeIndex(unique bool, cols []string) string {
+	kind := "index"
+	if unique {
+		kind = "unique index"
+	}
+	return fmt.Sprintf("%s on (%s)", kind, strings.Join(cols, ", "))
+}
+
+// writesNull reports whether the model can store NULL in f: pointers, nil
+// slices and valuers such as gorm.DeletedAt can, plain values cannot.
+func writesNull(f *schema.Field) bool {
+	switch f.FieldType.Kind() {
+	case reflect.Pointer, reflect.Slice:
+		return true
+	}
+	return f.FieldType.Implements(reflect.TypeOf((*driver.Valuer)(nil)).Elem())
+}
+
+// typeMatches reports whether the database type dbType can hold f, and
+// otherwise what the model expects. A type set in the gorm tag must match
+// exactly on PostgreSQL; everything else is compared by the kind of Go
+// value stored.
+func typeMatches(dialect string, f *schema.Field, dbType string) (string, bool) {
+	dbType = strings.ToLower(dbType)
+	if tag := strings.ToLower(f.TagSettings["TYPE"]); tag != "" && dialect == "postgres" {
+		return tag, dbType == tag
+	}
+
+	kind := valueKind(f)
+	var accepted []string
+	if dialect == "postgres" {
+		accepted = postgresTypes[kind]
+		return strings.Join(accepted, " or "), slices.Contains(accepted, dbType)
+	}
+
+	// SQLite columns have an affinity derived from the declared type.
+	accepted = sqliteAffinities[kind]
+	for _, a := range accepted {
+		if strings.Contains(dbType, a) {
+			return strings.Join(accepted, " or "), true
+		}
+	}
+	return strings.Join(accepted, " or "), false
+}
+
+// valueKind is the gorm data type of the value f stores, looking through
+// pointers and gorm.DeletedAt.
+func valueKind(f *schema.Field) schema.DataType {
+	t := f.FieldType
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}) || t == reflect.TypeOf(gorm.DeletedAt{}):
+		return schema.Time
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return schema.Bytes
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return schema.Bool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return schema.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema.Uint
+	case reflect.Float32, reflect.Float64:
+		return schema.Float
+	}
+	return schema.String
+}
+
+// postgresTypes lists the information_schema data types each kind of
+// value fits in without loss. NUMERIC is deliberately not a float type:
+// it round-trips through float64 inexactly.
+var postgresTypes = map[schema.DataType][]string{
+	schema.Bool:   {"boolean"},
+	schema.Int:    {"smallint", "integer", "bigint"},
+	schema.Uint:   {"smallint", "integer", "bigint"},
+	schema.Float:  {"double precision", "real"},
+	schema.String: {"text", "character varying", "character"},
+	schema.Time:   {"timestamp with time zone", "timestamp without time zone"},
+	schema.Bytes:  {"bytea"},
+}
+
+// sqliteAffinities lists, per kind of value, the substrings of a declared
+// SQLite type that give a fitting column affinity.
+var sqliteAffinities = map[schema.DataType][]string{
+	schema.Bool:   {"bool", "int", "numeric"},
+	schema.Int:    {"int"},
+	schema.Uint:   {"int"},
+	schema.Float:  {"real", "floa", "doub"},
+	schema.String: {"text", "char", "clob"},
+	schema.Time:   {"datetime", "timestamp", "date"},
+	schema.Bytes:  {"blob"},
+}
@@ -0,0 +1,94 @@
+package drift
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// introspectPostgres reads tables' columns from information_schema. Index
+// definitions are not part of information_schema, so they come from
+// pg_catalog. Primary key indexes are left out; the columns' nullability
+// already covers them.
+func introspectPostgres(db *gorm.DB, tables []string) ([]column, []index, error) {
+	var cols []struct {
+		TableName, ColumnName, DataType, IsNullable string
+	}
+	if err := db.Raw(`
+		SELECT table_name, column_name, data_type, is_nullable
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name IN ?
+		ORDER BY table_name, ordinal_position`, tables).Scan(&cols).Error; err != nil {
+		return nil, nil, fmt.Errorf("read columns: %w", err)
+	}
+	columns := make([]column, 0, len(cols))
+	for _, c := range cols {
+		columns = append(columns, column{Table: c.TableName, Name: c.ColumnName, Type: c.DataType, NotNull: c.IsNullable == "NO"})
+	}
+
+	var rows []indexRow
+	if err := db.Raw(`
+		SELECT t.relname AS table_name, i.relname AS index_name, ix.indisunique AS is_unique, a.attname AS column_name
+		FROM pg_index ix
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE n.nspname = current_schema() AND t.relname IN ? AND NOT ix.indisprimary
+		ORDER BY t.relname, i.relname, k.ord`, tables).Scan(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("read indexes: %w", err)
+	}
+	return columns, groupIndexes(rows), nil
+}
+
+// introspectSQLite reads tables' columns and indexes through the
+// table_info, index_list and index_info pragmas, SQLite having no
+// information_schema. Primary key indexes are left out, as on PostgreSQL.
+func introspectSQLite(db *gorm.DB, tables []string) ([]column, []index, error) {
+	var cols []struct {
+		TableName, ColumnName, DataType string
+		NotNull, PK                     int
+	}
+	if err := db.Raw(`
+		SELECT m.name AS table_name, p.name AS column_name, p.type AS data_type, p."notnull" AS not_null, p.pk AS pk
+		FROM sqlite_master m JOIN pragma_table_info(m.name) p
+		WHERE m.type = 'table' AND m.name IN ?
+		ORDER BY m.name, p.cid`, tables).Scan(&cols).Error; err != nil {
+		return nil, nil, fmt.Errorf("read columns: %w", err)
+	}
+	columns := make([]column, 0, len(cols))
+	for _, c := range cols {
+		columns = append(columns, column{Table: c.TableName, Name: c.ColumnName, Type: c.DataType, NotNull: c.NotNull == 1 || c.PK > 0})
+	}
+
+	var rows []indexRow
+	if err := db.Raw(`
+		SELECT m.name AS table_name, il.name AS index_name, il."unique" AS is_unique, ii.name AS column_name
+		FROM sqlite_master m
+		JOIN pragma_index_list(m.name) il
+		JOIN pragma_index_info(il.name) ii
+		WHERE m.type = 'table' AND m.name IN ? AND il.origin != 'pk'
+		ORDER BY m.name, il.name, ii.seqno`, tables).Scan(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("read indexes: %w", err)
+	}
+	return columns, groupIndexes(rows), nil
+}
+
+// indexRow is one column of one index, in index column order.
+type indexRow struct {
+	TableName, IndexName, ColumnName string
+	IsUnique                         bool
+}
+
+func groupIndexes(rows []indexRow) []index {
+	var indexes []index
+	for _, r := range rows {
+		if n := len(indexes); n > 0 && indexes[n-1].Table == r.TableName && indexes[n-1].Name == r.IndexName {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, r.ColumnName)
+			continue
+		}
+		indexes = append(indexes, index{Table: r.TableName, Name: r.IndexName, Unique: r.IsUnique, Columns: []string{r.ColumnName}})
+	}
+	return indexes
+}
@@ -0,0 +1,435 @@
+// Package migrations applies the numbered SQL migrations embedded in the
+// binary. Each dialect has its own directory of NNNN_name.up.sql and
+// NNNN_name.down.sql pairs; applied versions are recorded, with a checksum
+// of their up script, in the schema_versions table.
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"go-demo/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// historyTable records applied migrations. Its DDL is portable between the
+// supported dialects.
+const historyTable = "schema_versions"
+
+// lockKey identifies the PostgreSQL advisory lock held while migrating.
+const lockKey = 684_032_117
+
+// legacyBaseline is the last version adoptLegacy records as applied on a
+// schema created by the boot-time migrations of earlier releases.
+const legacyBaseline = 3
+
+var (
+	// ErrChecksumMismatch means an applied migration's up script was edited
+	// afterwards.
+	ErrChecksumMismatch = errors.New("migration changed after it was applied")
+	// ErrUnknownVersion means the database records a version this binary
+	// has no script for, e.g. after running an older build.
+	ErrUnknownVersion = errors.New("applied migration has no script")
+)
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // hex sha256 of Up
+}
+
+// Status describes one migration, known to the binary or recorded as
+// applied. AppliedAt is nil while it is pending.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Modified is set when the applied up script differs from the embedded
+	// one; Missing when no embedded script exists for the version.
+	Modified bool
+	Missing  bool
+}
+
+type historyRow struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (historyRow) TableName() string { return historyTable }
+
+// Migrator runs the migrations of db's dialect against db.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New loads the migrations for db's dialect.
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// load reads the embedded migrations of dialect, ordered by version, and
+// checks that every version has both scripts.
+func load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %w", dialect, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migration %s/%s: name must look like 0001_name.up.sql", dialect, e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(files, path.Join(dialect, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+			sum := sha256.Sum256(body)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d (%s) needs both an up and a down script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// Status lists every known or applied migration by version. It does not
+// take the lock or create the history table.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if row, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &row.AppliedAt
+			s.Modified = row.Checksum != mig.Checksum
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, row := range applied {
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, AppliedAt: &row.AppliedAt, Missing: true})
+	}
+	slices.SortFunc(statuses, func(a, b Status) int { return a.Version - b.Version })
+	return statuses, nil
+}
+
+// Pending returns the migrations Up would apply.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return m.pending(applied), nil
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns the ones applied. It refuses to run when an
+// applied migration was edited or is unknown to this binary.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(db *gorm.DB) error {
+		applied, err := m.prepare(db)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.pending(applied) {
+			if err := m.apply(db, mig); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the n most recently applied migrations, newest first,
+// and returns the ones rolled back.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("down needs a positive count, got %d", n)
+	}
+	var done []Migration
+	err := m.locked(ctx, func(db *gorm.DB) error {
+		applied, err := m.prepare(db)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.latest(applied, n) {
+			if err := m.revert(db, mig); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Redo rolls back the most recently applied migration and applies it
+// again, which is how a migration under development is re-run.
+func (m *Migrator) Redo(ctx context.Context) (Migration, error) {
+	var redone Migration
+	err := m.locked(ctx, func(db *gorm.DB) error {
+		applied, err := m.prepare(db)
+		if err != nil {
+			return err
+		}
+		latest := m.latest(applied, 1)
+		if len(latest) == 0 {
+			return errors.New("no applied migration to redo")
+		}
+		redone = latest[0]
+		if err := m.revert(db, redone); err != nil {
+			return err
+		}
+		return m.apply(db, redone)
+	})
+	return redone, err
+}
+
+// locked runs fn while holding the migration lock, so concurrent boots or
+// migrate runs apply each migration once. PostgreSQL takes a session
+// advisory lock on one pooled connection. SQLite has no advisory locks;
+// the whole run is one immediate transaction holding the database's write
+// lock instead, so there each migration is a savepoint of it.
+func (m *Migrator) locked(ctx context.Context, fn func(db *gorm.DB) error) error {
+	db := m.db.WithContext(ctx)
+	if db.Dialector.Name() != "postgres" {
+		return db.Transaction(fn)
+	}
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		// unlock on a context that outlives ctx, or a cancelled run would
+		// leave the session holding the lock
+		defer conn.WithContext(context.WithoutCancel(ctx)).Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		return fn(conn)
+	})
+}
+
+// prepare creates the history table if needed, adopts a schema created by
+// the boot-time migrations of earlier releases, and checks that applied
+// migrations still match their scripts. It returns the applied versions.
+func (m *Migrator) prepare(db *gorm.DB) (map[int]historyRow, error) {
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + historyTable + ` (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`).Error; err != nil {
+		return nil, fmt.Errorf("create %s: %w", historyTable, err)
+	}
+
+	applied, err := m.applied(db)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 && db.Migrator().HasTable("users") {
+		if err := m.adoptLegacy(db); err != nil {
+			return nil, err
+		}
+		if applied, err = m.applied(db); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, row := range applied {
+		i := slices.IndexFunc(m.migrations, func(mig Migration) bool { return mig.Version == row.Version })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: version %d (%s)", ErrUnknownVersion, row.Version, row.Name)
+		}
+		if m.migrations[i].Checksum != row.Checksum {
+			return nil, fmt.Errorf("%w: version %d (%s)", ErrChecksumMismatch, row.Version, row.Name)
+		}
+	}
+	return applied, nil
+}
+
+// adoptLegacy takes over a schema created by the boot-time migrations of
+// earlier releases. The last release only ran auto_migrate_v1..v3, so
+// upgradeLegacy first adds what the baseline scripts create beyond that;
+// the baseline is then recorded as applied, in the same transaction.
+func (m *Migrator) adoptLegacy(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := m.upgradeLegacy(tx); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > legacyBaseline {
+				break
+			}
+			if err := tx.Create(&historyRow{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum, AppliedAt: time.Now().UTC()}).Error; err != nil {
+				return fmt.Errorf("record baseline migration %d: %w", mig.Version, err)
+			}
+		}
+		logger.Log.Info().Int("baseline", legacyBaseline).Msg("existing schema adopted; baseline migrations recorded as applied")
+		return nil
+	})
+}
+
+// upgradeLegacy adds to a legacy schema whatever it lacks of the baseline:
+// optimistic locking and soft delete columns, unique UUID indexes, the
+// entity UUID of queued events and the idempotency keys table. Each piece
+// is checked for first, as builds between releases added some of them on
+// boot.
+func (m *Migrator) upgradeLegacy(db *gorm.DB) error {
+	timestamp := "DATETIME"
+	if db.Dialector.Name() == "postgres" {
+		timestamp = "TIMESTAMPTZ"
+	}
+	migr := db.Migrator()
+	var stmts []string
+	addColumn := func(table, column, def string) {
+		if !migr.HasColumn(table, column) {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def))
+		}
+	}
+	for _, table := range []string{"users", "products"} {
+		addColumn(table, "version", "BIGINT NOT NULL DEFAULT 1")
+		addColumn(table, "deleted_at", timestamp)
+		stmts = append(stmts,
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_deleted_at ON %[1]s (deleted_at)", table),
+			fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS idx_%[1]s_uuid ON %[1]s (uuid)", table),
+		)
+	}
+	for _, table := range []string{"audit_logs", "notification_outboxes"} {
+		addColumn(table, "entity_uuid", "TEXT")
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_entity_uuid ON %[1]s (entity_uuid)", table))
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("upgrade legacy schema: %w", err)
+		}
+	}
+
+	if !migr.HasTable("idempotency_keys") {
+		i := slices.IndexFunc(m.migrations, func(mig Migration) bool { return mig.Name == "create_idempotency_keys" })
+		if i < 0 {
+			return errors.New("upgrade legacy schema: no migration creates idempotency_keys")
+		}
+		if err := db.Exec(m.migrations[i].Up).Error; err != nil {
+			return fmt.Errorf("upgrade legacy schema: create idempotency_keys: %w", err)
+		}
+	}
+	return nil
+}
+
+// applied reads the history table; a missing table means nothing applied.
+func (m *Migrator) applied(db *gorm.DB) (map[int]historyRow, error) {
+	applied := map[int]historyRow{}
+	if !db.Migrator().HasTable(historyTable) {
+		return applied, nil
+	}
+	var rows []historyRow
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("read %s: %w", historyTable, err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) pending(applied map[int]historyRow) []Migration {
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending
+}
+
+// latest returns up to n applied migrations, newest first.
+func (m *Migrator) latest(applied map[int]historyRow, n int) []Migration {
+	var latest []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(latest) < n; i-- {
+		if _, ok := applied[m.migrations[i].Version]; ok {
+			latest = append(latest, m.migrations[i])
+		}
+	}
+	return latest
+}
+
+func (m *Migrator) apply(db *gorm.DB, mig Migration) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mig.Up).Error; err != nil {
+			return err
+		}
+		return tx.Create(&historyRow{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum, AppliedAt: time.Now().UTC()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("apply migration %d (%s): %w", mig.Version, mig.Name, err)
+	}
+	logger.Log.Info().Int("version", mig.Version).Str("name", mig.Name).Msg("migration applied")
+	return nil
+}
+
+func (m *Migrator) revert(db *gorm.DB, mig Migration) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mig.Down).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&historyRow{Version: mig.Version}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("roll back migration %d (%s): %w", mig.Version, mig.Name, err)
+	}
+	logger.Log.Info().Int("version", mig.Version).Str("name", mig.Name).Msg("migration rolled back")
+	return nil
+}
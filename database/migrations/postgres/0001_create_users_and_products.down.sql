@@ -0,0 +1,5 @@
+DROP TABLE products;
+DROP TABLE users;
+
+-- Bookkeeping of the boot-time migrations this runner replaced.
+DROP TABLE IF EXISTS schema_migrations;
@@ -0,0 +1,25 @@
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    uuid UUID NOT NULL,
+    name TEXT NOT NULL,
+    role TEXT NOT NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_users_uuid ON users (uuid);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE products (
+    id BIGSERIAL PRIMARY KEY,
+    uuid UUID NOT NULL,
+    name TEXT NOT NULL,
+    price NUMERIC NOT NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_products_uuid ON products (uuid);
+CREATE INDEX idx_products_deleted_at ON products (deleted_at);
@@ -0,0 +1,3 @@
+DROP TABLE notification_jobs;
+DROP TABLE notification_outboxes;
+DROP TABLE audit_logs;
@@ -0,0 +1,42 @@
+CREATE TABLE audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    action TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id BIGINT NOT NULL,
+    entity_uuid TEXT,
+    message TEXT NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    processed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_audit_logs_entity_uuid ON audit_logs (entity_uuid);
+CREATE INDEX idx_audit_logs_processed_at ON audit_logs (processed_at);
+
+CREATE TABLE notification_outboxes (
+    id BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    entity_uuid TEXT,
+    status TEXT DEFAULT 'PENDING',
+    processed_at TIMESTAMPTZ,
+    error TEXT,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_notification_outboxes_entity_uuid ON notification_outboxes (entity_uuid);
+CREATE INDEX idx_notification_outboxes_status ON notification_outboxes (status);
+
+-- Deprecated queue, kept until nothing reads it.
+CREATE TABLE notification_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    type TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT DEFAULT 'PENDING',
+    processed_at TIMESTAMPTZ,
+    error TEXT,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_notification_jobs_status ON notification_jobs (status);
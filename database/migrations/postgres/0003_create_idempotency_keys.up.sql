@@ -0,0 +1,12 @@
+CREATE TABLE idempotency_keys (
+    idempotency_key VARCHAR(255) PRIMARY KEY,
+    fingerprint VARCHAR(64) NOT NULL,
+    status TEXT NOT NULL DEFAULT 'PROCESSING',
+    response_status BIGINT,
+    response_headers TEXT,
+    response_body BYTEA,
+    created_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,42 @@
+CREATE TABLE audit_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    entity TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    entity_uuid TEXT,
+    message TEXT NOT NULL,
+    timestamp DATETIME NOT NULL,
+    processed_at DATETIME,
+    created_at DATETIME
+);
+
+CREATE INDEX idx_audit_logs_entity_uuid ON audit_logs (entity_uuid);
+CREATE INDEX idx_audit_logs_processed_at ON audit_logs (processed_at);
+
+CREATE TABLE notification_outboxes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    entity_uuid TEXT,
+    status TEXT DEFAULT 'PENDING',
+    processed_at DATETIME,
+    error TEXT,
+    created_at DATETIME
+);
+
+CREATE INDEX idx_notification_outboxes_entity_uuid ON notification_outboxes (entity_uuid);
+CREATE INDEX idx_notification_outboxes_status ON notification_outboxes (status);
+
+-- Deprecated queue, kept until nothing reads it.
+CREATE TABLE notification_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT DEFAULT 'PENDING',
+    processed_at DATETIME,
+    error TEXT,
+    created_at DATETIME
+);
+
+CREATE INDEX idx_notification_jobs_status ON notification_jobs (status);
@@ -0,0 +1,12 @@
+CREATE TABLE idempotency_keys (
+    idempotency_key TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'PROCESSING',
+    response_status INTEGER,
+    response_headers TEXT,
+    response_body BLOB,
+    created_at DATETIME,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteParams tune the connection for an API and worker sharing one file:
+// WAL lets readers run alongside the writer, busy_timeout waits for the
+// write lock instead of failing, and immediate transactions take that lock
+// up front so two transactions cannot deadlock upgrading a read lock.
+const sqliteParams = "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+// OpenSQLite opens the SQLite file at path, creating it if needed.
+// Constraint errors are translated to GORM's portable ones, which the
+// repositories map like their PostgreSQL counterparts.
+func OpenSQLite(path string) (*gorm.DB, error) {
+	return gorm.Open(sqlite.Open("file:"+path+sqliteParams), &gorm.Config{TranslateError: true})
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// WithTx returns a copy of ctx whose database work, looked up through Conn,
+// runs inside tx.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// Conn returns the handle to use for ctx: the transaction bound to it by
+// WithTx, or db scoped to ctx so cancelling the request cancels its
+// queries.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.WithContext(ctx)
+}
+
+// Transaction runs fn as one unit of work on db: everything fn does through
+// the ctx it is given commits together when fn returns nil and is rolled
+// back when it returns an error or panics. Called with a ctx that already
+// carries a transaction, the unit of work nests in it as a savepoint, so
+// the outer transaction still decides what is finally committed.
+func Transaction(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) error {
+	return Conn(ctx, db).Transaction(func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	})
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"go-demo/pkg/problem"
+)
+
+// adminOnly wraps h so it only runs for requests carrying s.AdminToken.
+func (s *Server) adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.AdminToken == "" {
+			problem.Error(w, r, http.StatusNotFound, "")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			problem.Error(w, r, http.StatusUnauthorized, "admin bearer token required")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+			problem.Error(w, r, http.StatusForbidden, "invalid admin token")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// AdminHandler guards h like the admin routes, for admin endpoints mounted
+// outside RegisterRoutes.
+func (s *Server) AdminHandler(h http.Handler) http.Handler {
+	return s.adminOnly(h.ServeHTTP)
+}
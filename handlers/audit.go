@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"go-demo/models"
+	"go-demo/pkg/logger"
+)
+
+// auditDetached publishes an event outside any transaction: for reads, which
+// change nothing, and for imports, which commit batch by batch. A failure is
+// logged rather than failing a response that has already been written.
+func (s *Server) auditDetached(r *http.Request, ev models.AuditLog) {
+	if err := s.Audit.Publish(r.Context(), ev); err != nil {
+		logger.Log.Error().Err(err).Str("audit_action", ev.Action).Str("audit_entity", ev.Entity).
+			Msg("failed to publish audit event")
+	}
+}
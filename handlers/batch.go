@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"go-demo/pkg/apiversion"
+	"go-demo/pkg/problem"
+	"go-demo/pkg/validator"
+)
+
+// batchHeaders are the request headers an operation may set.
+var batchHeaders = map[string]bool{"Content-Type": true, "If-Match": true, "If-None-Match": true}
+
+type batchOperation struct {
+	Method  string            `json:"method" validate:"required,oneof=GET POST PUT PATCH DELETE"`
+	Path    string            `json:"path" validate:"required,startswith=/"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchRequest holds at most 100 operations.
+type batchRequest struct {
+	Operations []batchOperation `json:"operations" validate:"required,min=1,max=100,dive"`
+}
+
+type batchResult struct {
+	Index   int               `json:"index"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchResponse reports every operation that ran. When Committed is false
+// the batch was rolled back and the last result is the one that failed.
+type batchResponse struct {
+	Committed bool          `json:"committed"`
+	Results   []batchResult `json:"results"`
+}
+
+var errBatchFailed = errors.New("batch operation failed")
+
+// batchRoutes serves the operations a batch may contain: the user and
+// product routes, but not imports, admin routes or batches.
+func (s *Server) batchRoutes() *http.ServeMux {
+	s.batchOnce.Do(func() {
+		s.batchMux = http.NewServeMux()
+		for _, rt := range s.routes() {
+			resource := strings.HasPrefix(rt.path, "/users") || strings.HasPrefix(rt.path, "/products")
+			if resource && rt.path != "/products/import" {
+				s.batchMux.HandleFunc(rt.method+" "+rt.path, rt.handler)
+			}
+		}
+	})
+	return s.batchMux
+}
+
+// Batch handles POST /batch. It runs an ordered list of user and product
+// operations, each as if sent on its own, inside one transaction. The
+// first operation answering with an error status stops the batch and
+// rolls back everything written so far, audit and outbox rows included.
+func (s *Server) Batch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if p := decodeJSON(w, r, &req); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+	if err := validator.Validate.Struct(req); err != nil {
+		problem.Write(w, r, problem.FromValidation(err))
+		return
+	}
+
+	routes := s.batchRoutes()
+	for i, op := range req.Operations {
+		for name := range op.Headers {
+			if !batchHeaders[http.CanonicalHeaderKey(name)] {
+				problem.Error(w, r, http.StatusBadRequest, fmt.Sprintf("operation %d: header %q is not allowed in a batch", i, name))
+				return
+			}
+		}
+		probe, err := http.NewRequest(op.Method, op.Path, nil)
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest, fmt.Sprintf("operation %d: invalid path %q", i, op.Path))
+			return
+		}
+		if _, pattern := routes.Handler(probe); pattern == "" {
+			problem.Error(w, r, http.StatusBadRequest, fmt.Sprintf("operation %d: %s %s cannot be batched", i, op.Method, probe.URL.Path))
+			return
+		}
+	}
+
+	// Operations answer in the version the batch was sent to, so their
+	// Location headers point back into it
+	version, versioned := apiversion.FromContext(r.Context())
+	resp := batchResponse{Results: make([]batchResult, 0, len(req.Operations))}
+	err := s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		if versioned {
+			ctx = apiversion.NewContext(ctx, version)
+		}
+		for i, op := range req.Operations {
+			res := runBatchOperation(ctx, routes, op)
+			res.Index = i
+			resp.Results = append(resp.Results, res)
+			if res.Status >= http.StatusBadRequest {
+				return errBatchFailed
+			}
+		}
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		resp.Committed = true
+		writeJSON(w, http.StatusOK, resp)
+	case errors.Is(err, errBatchFailed):
+		status := http.StatusUnprocessableEntity
+		if resp.Results[len(resp.Results)-1].Status >= http.StatusInternalServerError {
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, resp)
+	default:
+		writeRepoError(w, r, "batch", err)
+	}
+}
+
+// runBatchOperation serves op through routes with ctx, which carries the
+// batch transaction and API version, and captures the response.
+func runBatchOperation(ctx context.Context, routes http.Handler, op batchOperation) batchResult {
+	var body []byte
+	if len(op.Body) > 0 && string(op.Body) != "null" {
+		body = op.Body
+	}
+	req, _ := http.NewRequestWithContext(ctx, op.Method, op.Path, bytes.NewReader(body))
+	req.RequestURI = op.Path
+	for name, value := range op.Headers {
+		req.Header.Set(name, value)
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+
+	res := batchResult{Status: rec.Code}
+	for _, name := range []string{"Location", "ETag"} {
+		if v := rec.Header().Get(name); v != "" {
+			if res.Headers == nil {
+				res.Headers = map[string]string{}
+			}
+			res.Headers[name] = v
+		}
+	}
+	if rec.Body.Len() > 0 {
+		mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		if (mediaType == "application/json" || mediaType == problem.ContentType) && json.Valid(rec.Body.Bytes()) {
+			res.Body = bytes.TrimSpace(rec.Body.Bytes())
+		} else {
+			res.Body, _ = json.Marshal(rec.Body.String())
+		}
+	}
+	return res
+}
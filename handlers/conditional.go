@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-demo/pkg/problem"
+)
+
+// etag returns the strong entity tag for a resource version.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseETags splits an If-Match / If-None-Match header into its entity
+// tags. The result is nil for "*".
+func parseETags(header string) (tags []string, any bool) {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return nil, true
+		}
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags, false
+}
+
+// ifMatchVersion resolves the If-Match header of a write into the version
+// the write must be conditional on; 0 means unconditional. current is
+// called only when the header lists several tags and the stored version
+// is needed to pick one.
+func (s *Server) ifMatchVersion(r *http.Request, current func() (int, error)) (int, *problem.Problem, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if s.RequireIfMatch {
+			return 0, problem.New(http.StatusPreconditionRequired, "this request must be conditional; send If-Match with the resource ETag"), nil
+		}
+		return 0, nil, nil
+	}
+
+	tags, any := parseETags(header)
+	if any {
+		return 0, nil, nil
+	}
+
+	// Strong comparison: weak tags never match.
+	var versions []int
+	for _, t := range tags {
+		if strings.HasPrefix(t, "W/") || len(t) < 2 || t[0] != '"' || t[len(t)-1] != '"' {
+			continue
+		}
+		if v, err := strconv.Atoi(t[1 : len(t)-1]); err == nil && v > 0 {
+			versions = append(versions, v)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, preconditionFailed(), nil
+	case 1:
+		return versions[0], nil, nil
+	}
+
+	v, err := current()
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, want := range versions {
+		if want == v {
+			return v, nil, nil
+		}
+	}
+	return 0, preconditionFailed(), nil
+}
+
+// notModified reports whether the If-None-Match header of a GET matches
+// the current version, in which case the caller should answer 304.
+func notModified(r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	tags, any := parseETags(header)
+	if any {
+		return true
+	}
+
+	// Weak comparison, as required for If-None-Match.
+	current := etag(version)
+	for _, t := range tags {
+		if strings.TrimPrefix(t, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+func preconditionFailed() *problem.Problem {
+	return problem.New(http.StatusPreconditionFailed, "the resource has been modified; fetch it again and retry with the new ETag")
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go-demo/pkg/problem"
+)
+
+// maxBodyBytes caps the size of JSON request bodies.
+const maxBodyBytes = 1 << 20
+
+// decodeJSON strictly decodes a single JSON object from the request body
+// into dst. Unknown fields, trailing data, oversized bodies and non-JSON
+// content types are rejected with a problem describing what went wrong.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) *problem.Problem {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || mt != "application/json" {
+			return &problem.Problem{
+				Type:   problem.TypeUnsupported,
+				Title:  "Unsupported media type",
+				Status: http.StatusUnsupportedMediaType,
+				Detail: fmt.Sprintf("content type %q is not supported; use application/json", ct),
+			}
+		}
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeProblem(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return malformed("request body must contain a single JSON object")
+	}
+	return nil
+}
+
+// decodeProblem turns an encoding/json error into a client-facing problem.
+func decodeProblem(err error) *problem.Problem {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return malformed("request body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return malformed("request body contains malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return malformed(fmt.Sprintf("request body contains malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return malformed(fmt.Sprintf("field %q must be of type %s", typeErr.Field, typeErr.Type))
+		}
+		return malformed(fmt.Sprintf("request body must be a JSON %s", typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return malformed("request body contains unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field "))
+	case errors.As(err, &maxErr):
+		return &problem.Problem{
+			Type:   problem.TypeBodyTooLarge,
+			Title:  "Request body too large",
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit),
+		}
+	default:
+		return malformed("request body could not be decoded")
+	}
+}
+
+func malformed(detail string) *problem.Problem {
+	return &problem.Problem{
+		Type:   problem.TypeMalformedBody,
+		Title:  "Malformed request body",
+		Status: http.StatusBadRequest,
+		Detail: detail,
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go-demo/pkg/logger"
+	"go-demo/pkg/problem"
+	"go-demo/repositories"
+)
+
+// writeRepoError maps a repository error to a problem response. Driver
+// messages are logged, never sent to the client.
+func writeRepoError(w http.ResponseWriter, r *http.Request, entity string, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		problem.Error(w, r, http.StatusNotFound, entity+" not found")
+	case errors.Is(err, repositories.ErrConflict):
+		problem.Error(w, r, http.StatusConflict, entity+" conflicts with an existing "+entity)
+	case errors.Is(err, repositories.ErrNotInTrash):
+		problem.Error(w, r, http.StatusConflict, entity+" is not deleted")
+	case errors.Is(err, repositories.ErrVersionMismatch):
+		problem.Write(w, r, preconditionFailed())
+	case errors.Is(err, repositories.ErrConstraint):
+		problem.Error(w, r, http.StatusUnprocessableEntity, entity+" violates a data constraint")
+	case errors.Is(err, repositories.ErrInvalidCursor), errors.Is(err, repositories.ErrInvalidSort):
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+	default:
+		logger.Log.Error().Err(err).Str("method", r.Method).Str("path", r.URL.Path).Msg("repository error")
+		problem.Error(w, r, http.StatusInternalServerError, "")
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-demo/models"
+	"go-demo/pkg/logger"
+	"go-demo/pkg/problem"
+)
+
+// Media types a list endpoint can answer with, chosen by the Accept header.
+const (
+	csvType    = "text/csv"
+	ndjsonType = "application/x-ndjson"
+)
+
+var listTypes = []string{"application/json", csvType, ndjsonType}
+
+// exportFlushEvery is how many rows are written between flushes, so a
+// large export reaches the client while it is still being read.
+const exportFlushEvery = 100
+
+// negotiateList picks the list response type from the Accept header. An
+// absent header or a wildcard gets JSON; ok is false when the client
+// accepts none of listTypes.
+func negotiateList(r *http.Request) (mediaType string, ok bool) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return listTypes[0], true
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		for _, t := range listTypes {
+			if q > bestQ && (mt == t || mt == "*/*" || mt == strings.Split(t, "/")[0]+"/*") {
+				best, bestQ = t, q
+				break
+			}
+		}
+	}
+	return best, best != ""
+}
+
+func notAcceptable(w http.ResponseWriter, r *http.Request) {
+	problem.Error(w, r, http.StatusNotAcceptable, "list responses are available as "+strings.Join(listTypes, ", "))
+}
+
+// exportList streams rows to w as CSV or NDJSON. Headers are only sent
+// once the first row (or the end of an empty result) is reached, so an
+// error before that still gets a problem response; an error mid-stream
+// can only be logged and the response cut short.
+func exportList[T any](w http.ResponseWriter, r *http.Request, entity, mediaType string,
+	header []string, record func(T) []string, run func(fn func(T) error) error) {
+
+	var (
+		started bool
+		rows    int
+		cw      = csv.NewWriter(w)
+		enc     = json.NewEncoder(w)
+		flusher = http.NewResponseController(w)
+	)
+	start := func() {
+		started = true
+		ext := "csv"
+		if mediaType == ndjsonType {
+			ext = "ndjson"
+		}
+		w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+entity+"s."+ext+`"`)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		if mediaType == csvType {
+			cw.Write(header)
+		}
+	}
+
+	err := run(func(item T) error {
+		if !started {
+			start()
+		}
+		if mediaType == csvType {
+			cw.Write(record(item))
+		} else if err := enc.Encode(item); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushEvery == 0 {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+		return nil
+	})
+
+	if err != nil && !started {
+		writeRepoError(w, r, entity, err)
+		return
+	}
+	if !started {
+		start()
+	}
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		logger.Log.Error().Err(err).Str("entity", entity).Int("rows", rows).Msg("export aborted")
+	}
+}
+
+// csvCell neutralises values a spreadsheet would run as a formula.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+var userCSVHeader = []string{"uuid", "name", "role", "version", "created_at", "deleted_at"}
+
+func userCSVRecord(u models.User) []string {
+	return []string{
+		u.UUID,
+		csvCell(u.Name),
+		csvCell(u.Role),
+		strconv.Itoa(u.Version),
+		csvTime(u.CreatedAt),
+		csvTime(u.DeletedAt.Time),
+	}
+}
+
+var productCSVHeader = []string{"uuid", "name", "price", "version", "created_at", "deleted_at"}
+
+func productCSVRecord(p models.Product) []string {
+	return []string{
+		p.UUID,
+		csvCell(p.Name),
+		strconv.FormatFloat(p.Price, 'f', -1, 64),
+		strconv.Itoa(p.Version),
+		csvTime(p.CreatedAt),
+		csvTime(p.DeletedAt.Time),
+	}
+}
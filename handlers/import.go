@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-demo/models"
+	"go-demo/pkg/logger"
+	"go-demo/pkg/problem"
+	"go-demo/pkg/validator"
+	"go-demo/repositories"
+	"go-demo/worker"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxImportBytes caps an import file; larger catalogs are split.
+	maxImportBytes = 32 << 20
+	// importBatchSize is how many valid rows share one transaction.
+	importBatchSize = 500
+)
+
+// importReport is the response of POST /products/import.
+type importReport struct {
+	DryRun   bool                        `json:"dry_run"`
+	Created  int                         `json:"created"`
+	Updated  int                         `json:"updated"`
+	Rejected int                         `json:"rejected"`
+	Rows     []repositories.ImportResult `json:"rows"`
+}
+
+func (rep *importReport) add(res repositories.ImportResult) {
+	switch res.Status {
+	case repositories.ImportCreated:
+		rep.Created++
+	case repositories.ImportUpdated:
+		rep.Updated++
+	default:
+		rep.Rejected++
+	}
+	rep.Rows = append(rep.Rows, res)
+}
+
+// ImportProducts handles POST /products/import. The body is a CSV file with
+// a header row (uuid, name, price; the extra export columns are ignored) or
+// NDJSON with one product per line. Valid rows are upserted by uuid, or by
+// name when no uuid is given, importBatchSize rows per transaction. With
+// ?dry_run=true nothing is written and the report shows what would happen;
+// each dry-run batch is rolled back on its own, so a name created in one
+// batch is reported as created again by a later one. When the file or the
+// store fails after earlier batches were committed, the problem carries
+// their report as its "report" member.
+func (s *Server) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if s := r.URL.Query().Get("dry_run"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest, fmt.Sprintf("invalid dry_run %q", s))
+			return
+		}
+		dryRun = b
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var read func(io.Reader, func(line int, p models.Product, reason string)) error
+	switch mediaType {
+	case csvType:
+		read = readProductCSV
+	case ndjsonType:
+		read = readProductNDJSON
+	default:
+		problem.Write(w, r, &problem.Problem{
+			Type:   problem.TypeUnsupported,
+			Title:  "Unsupported media type",
+			Status: http.StatusUnsupportedMediaType,
+			Detail: "Content-Type must be " + csvType + " or " + ndjsonType,
+		})
+		return
+	}
+
+	report := importReport{DryRun: dryRun, Rows: []repositories.ImportResult{}}
+	var (
+		batch    []repositories.ImportRow
+		rejected []repositories.ImportResult
+	)
+	// flush stores the pending batch and merges its results with the rows
+	// rejected before reaching the database, in line order.
+	flush := func() error {
+		var results []repositories.ImportResult
+		if len(batch) > 0 {
+			var err error
+			if results, err = s.Products.Import(r.Context(), batch, dryRun); err != nil {
+				return err
+			}
+		}
+		for _, res := range mergeByLine(rejected, results) {
+			report.add(res)
+		}
+		batch, rejected = batch[:0], rejected[:0]
+		return nil
+	}
+
+	var storeErr error
+	readErr := read(http.MaxBytesReader(w, r.Body, maxImportBytes), func(line int, p models.Product, reason string) {
+		if storeErr != nil {
+			return
+		}
+		if reason == "" {
+			reason = importValidate(p)
+		}
+		if reason != "" {
+			rejected = append(rejected, repositories.ImportResult{Line: line, Status: repositories.ImportRejected, Reason: reason})
+			return
+		}
+		batch = append(batch, repositories.ImportRow{Line: line, Product: p})
+		if len(batch) == importBatchSize {
+			storeErr = flush()
+		}
+	})
+	if storeErr == nil && readErr == nil {
+		storeErr = flush()
+	}
+
+	if readErr != nil || storeErr != nil {
+		var (
+			prob     *problem.Problem
+			tooLarge *http.MaxBytesError
+		)
+		switch {
+		case readErr == nil:
+			logger.Log.Error().Err(storeErr).Int("stored_rows", len(report.Rows)).Msg("product import failed")
+			prob = problem.Newf(http.StatusInternalServerError,
+				"import stopped after %d rows; rows before that were committed", len(report.Rows))
+		case errors.As(readErr, &tooLarge):
+			prob = &problem.Problem{
+				Type:   problem.TypeBodyTooLarge,
+				Title:  "Request body too large",
+				Status: http.StatusRequestEntityTooLarge,
+				Detail: fmt.Sprintf("import files are limited to %d bytes", maxImportBytes),
+			}
+		default:
+			prob = malformed(readErr.Error())
+		}
+		// Earlier batches stay committed; the report of their rows lets
+		// the client resume after them instead of importing them twice.
+		if !dryRun && len(report.Rows) > 0 {
+			prob.Extensions = map[string]any{"report": report}
+			if readErr != nil && report.Created+report.Updated > 0 {
+				logger.Log.Warn().Int("created", report.Created).Int("updated", report.Updated).
+					Msg("product import aborted after committing earlier batches")
+			}
+		}
+		problem.Write(w, r, prob)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+
+	// one summary event instead of one per row
+	summary := fmt.Sprintf("imported products: %d created, %d updated, %d rejected",
+		report.Created, report.Updated, report.Rejected)
+	if dryRun {
+		summary = "dry run, " + summary
+	}
+	s.auditDetached(r, worker.NewEvent(
+		"IMPORT",
+		"product",
+		"",
+		summary,
+	))
+}
+
+// importValidate runs the model validation on p and describes the
+// failures, or returns "" when p is valid.
+func importValidate(p models.Product) string {
+	err := validator.Validate.Struct(p)
+	if err == nil {
+		return ""
+	}
+	prob := problem.FromValidation(err)
+	if len(prob.Errors) == 0 {
+		return prob.Detail
+	}
+	reasons := make([]string, len(prob.Errors))
+	for i, fe := range prob.Errors {
+		reasons[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// mergeByLine merges two line-ordered result lists.
+func mergeByLine(a, b []repositories.ImportResult) []repositories.ImportResult {
+	out := make([]repositories.ImportResult, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if a[0].Line < b[0].Line {
+			out, a = append(out, a[0]), a[1:]
+		} else {
+			out, b = append(out, b[0]), b[1:]
+		}
+	}
+	return append(append(out, a...), b...)
+}
+
+// importColumns are the CSV columns an import reads; the remaining columns
+// of an export are accepted and ignored so an export can be re-imported.
+var importColumns = map[string]bool{"uuid": true, "name": true, "price": true}
+var ignoredColumns = map[string]bool{"version": true, "created_at": true, "deleted_at": true}
+
+// readProductCSV calls row for every record of a CSV import. A record that
+// cannot be turned into a product is passed with a reason; a broken file
+// or header is returned as an error.
+func readProductCSV(body io.Reader, row func(line int, p models.Product, reason string)) error {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return errors.New("CSV import needs a header row")
+	}
+	if err != nil {
+		return err
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !importColumns[name] && !ignoredColumns[name] {
+			return fmt.Errorf("unknown CSV column %q", name)
+		}
+		cols[name] = i
+	}
+	for _, required := range []string{"name", "price"} {
+		if _, ok := cols[required]; !ok {
+			return fmt.Errorf("CSV header is missing the %q column", required)
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			row(line, models.Product{}, fmt.Sprintf("has %d fields, header has %d", len(record), len(header)))
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := cols[name]; ok {
+				return uncsvCell(strings.TrimSpace(record[i]))
+			}
+			return ""
+		}
+		p := models.Product{UUID: strings.ToLower(field("uuid")), Name: field("name")}
+		if s := field("price"); s != "" {
+			price, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				row(line, p, fmt.Sprintf("price %q is not a number", s))
+				continue
+			}
+			p.Price = price
+		}
+		row(line, p, "")
+	}
+}
+
+// uncsvCell undoes csvCell, so exported values import unchanged.
+func uncsvCell(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// readProductNDJSON calls row for every non-blank line of an NDJSON import.
+func readProductNDJSON(body io.Reader, row func(line int, p models.Product, reason string)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var p models.Product
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			// reuse the body decoding messages, worded for a single line
+			row(line, models.Product{}, strings.Replace(decodeProblem(err).Detail, "request body", "line", 1))
+			continue
+		}
+		// server-managed fields are never taken from the file
+		p.ID, p.Version = 0, 0
+		p.CreatedAt = time.Time{}
+		p.DeletedAt = gorm.DeletedAt{}
+		p.UUID = strings.ToLower(p.UUID)
+		row(line, p, "")
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-demo/repositories"
+)
+
+// listParamNames are the query parameters every list endpoint accepts.
+var listParamNames = []string{"limit", "cursor", "sort", "include_total", "include_deleted"}
+
+// checkQueryParams rejects query parameters outside the endpoint's whitelist.
+func checkQueryParams(q url.Values, allowed ...string) error {
+	for name := range q {
+		ok := false
+		for _, a := range append(allowed, listParamNames...) {
+			if name == a {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("unsupported query parameter %q", name)
+		}
+	}
+	return nil
+}
+
+// parseListParams reads limit, cursor, sort and include_total.
+func parseListParams(q url.Values) (repositories.ListParams, error) {
+	p := repositories.ListParams{
+		Cursor: q.Get("cursor"),
+		Sort:   q.Get("sort"),
+	}
+
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return p, fmt.Errorf("invalid limit %q", s)
+		}
+		p.Limit = limit
+	}
+
+	if s := q.Get("include_total"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return p, fmt.Errorf("invalid include_total %q", s)
+		}
+		p.IncludeTotal = b
+	}
+
+	return p, nil
+}
+
+// parseTimeParam parses an RFC 3339 timestamp; an empty value yields the
+// zero time.
+func parseTimeParam(q url.Values, name string) (time.Time, error) {
+	s := q.Get(name)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: expected RFC 3339 timestamp", name, s)
+	}
+	return t, nil
+}
+
+// parseIncludeDeleted reads the include_deleted flag.
+func parseIncludeDeleted(q url.Values) (bool, error) {
+	s := q.Get("include_deleted")
+	if s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid include_deleted %q", s)
+	}
+	return b, nil
+}
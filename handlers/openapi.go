@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"go-demo/models"
+	"go-demo/pkg/openapi"
+	"go-demo/pkg/problem"
+	"go-demo/repositories"
+)
+
+const specTitle = "go-demo API"
+
+// APIVersion is the URL version RegisterRoutes' routes are served under.
+const APIVersion = "v1"
+
+// SpecVersion is the info.version of the published document. Bump it when
+// the contract changes.
+const SpecVersion = "1.0.0"
+
+var (
+	specOnce sync.Once
+	spec     *openapi.Document
+	specJSON []byte
+)
+
+// Spec returns the OpenAPI document for the routes RegisterRoutes mounts.
+// It is built once from the route table and the models.
+func Spec() *openapi.Document {
+	specOnce.Do(func() {
+		spec = buildSpec()
+		specJSON, _ = json.MarshalIndent(spec, "", "  ")
+	})
+	return spec
+}
+
+// ServeSpec handles GET /openapi.json.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	Spec()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
+
+func buildSpec() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       specTitle,
+		Version:     SpecVersion,
+		Description: "Users and products, addressed by UUID. Errors are RFC 7807 problem documents.",
+	})
+	doc.Servers = []openapi.Server{{URL: "/" + APIVersion}}
+
+	schemas := doc.Components.Schemas
+	for name, v := range map[string]any{"User": models.User{}, "Product": models.Product{}} {
+		s := openapi.SchemaOf(v)
+		schemas[name] = s
+		schemas[name+"Patch"] = patchSchema(s)
+		schemas[name+"Page"] = pageSchema(name)
+	}
+	problemSchema := openapi.SchemaOf(problem.Problem{})
+	problemSchema.Required = []string{"type", "title", "status"}
+	schemas["Problem"] = problemSchema
+	schemas["JSONPatch"] = jsonPatchSchema()
+	schemas["ImportReport"] = openapi.SchemaOf(importReport{})
+	importProblem := *problemSchema
+	importProblem.Properties = maps.Clone(problemSchema.Properties)
+	committed := openapi.Ref("ImportReport")
+	committed.Description = "rows processed before the import stopped, when earlier batches were committed"
+	importProblem.Properties["report"] = committed
+	schemas["ImportProblem"] = &importProblem
+	schemas["BatchRequest"] = openapi.SchemaOf(batchRequest{})
+	schemas["BatchResponse"] = openapi.SchemaOf(batchResponse{})
+
+	doc.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		"adminToken": {Type: "http", Scheme: "bearer"},
+	}
+
+	// The operations do not depend on the server's repositories, so the
+	// table of an unwired server documents every server.
+	for _, rt := range new(Server).routes() {
+		doc.AddOperation(rt.method, rt.path, rt.op)
+	}
+	return doc
+}
+
+// patchSchema is s with nothing required, for merge patch documents.
+func patchSchema(s *openapi.Schema) *openapi.Schema {
+	patch := *s
+	patch.Required = nil
+	return &patch
+}
+
+func pageSchema(item string) *openapi.Schema {
+	s := openapi.SchemaOf(repositories.Page[struct{}]{})
+	s.Properties["items"] = &openapi.Schema{Type: "array", Items: openapi.Ref(item)}
+	s.Required = []string{"items"}
+	return s
+}
+
+func jsonPatchSchema() *openapi.Schema {
+	closed := false
+	return &openapi.Schema{
+		Type:        "array",
+		Description: "RFC 6902 JSON Patch",
+		Items: &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"op":    {Type: "string", Enum: []any{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  {Type: "string"},
+				"from":  {Type: "string"},
+				"value": {},
+			},
+			Required:             []string{"op", "path"},
+			AdditionalProperties: &closed,
+		},
+	}
+}
+
+// Parameters shared by several operations.
+var (
+	idParam = &openapi.Parameter{
+		Name: "id", In: "path", Required: true,
+		Description: "Public UUID of the resource.",
+		Schema:      &openapi.Schema{Type: "string", Format: "uuid"},
+	}
+	includeDeletedParam = queryParam("include_deleted", "Also return soft-deleted records.", &openapi.Schema{Type: "boolean"})
+	nameContainsParam   = queryParam("name_contains", "Case-insensitive substring of the name.", &openapi.Schema{Type: "string"})
+	createdAfterParam   = queryParam("created_after", "Only records created after this RFC 3339 timestamp.", &openapi.Schema{Type: "string", Format: "date-time"})
+	ifMatchParam        = headerParam("If-Match", "ETag the client last saw; 412 when it is stale.")
+	ifNoneMatchParam    = headerParam("If-None-Match", "ETags the client has cached; 304 when one is current.")
+	idempotencyKeyParam = headerParam("Idempotency-Key", "Replays the stored response for a repeated key.")
+)
+
+func queryParam(name, description string, s *openapi.Schema) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "query", Description: description, Schema: s}
+}
+
+func headerParam(name, description string) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: "header", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+func jsonBody(schema string) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content:  map[string]*openapi.MediaType{"application/json": {Schema: openapi.Ref(schema)}},
+	}
+}
+
+func entityResponse(description, schema string, headers ...string) *openapi.Response {
+	resp := &openapi.Response{
+		Description: description,
+		Content:     map[string]*openapi.MediaType{"application/json": {Schema: openapi.Ref(schema)}},
+	}
+	for _, h := range headers {
+		if resp.Headers == nil {
+			resp.Headers = map[string]*openapi.Header{}
+		}
+		resp.Headers[h] = &openapi.Header{Schema: &openapi.Schema{Type: "string"}}
+	}
+	return resp
+}
+
+// responses builds an operation's response map: ok holds the success
+// responses and problems the error statuses it can answer with. Every
+// operation can also fail with 429 and 500.
+func responses(ok map[int]*openapi.Response, problems ...int) map[string]*openapi.Response {
+	out := map[string]*openapi.Response{}
+	for status, resp := range ok {
+		out[strconv.Itoa(status)] = resp
+	}
+	for _, status := range append(problems, http.StatusTooManyRequests, http.StatusInternalServerError) {
+		out[strconv.Itoa(status)] = &openapi.Response{
+			Description: http.StatusText(status),
+			Content:     map[string]*openapi.MediaType{problem.ContentType: {Schema: openapi.Ref("Problem")}},
+		}
+	}
+	return out
+}
+
+func listOp(id, tag, schema string, sorts []string, filters ...*openapi.Parameter) *openapi.Operation {
+	sortEnum := make([]any, len(sorts))
+	for i, s := range sorts {
+		sortEnum[i] = s
+	}
+	one := float64(1)
+
+	params := []*openapi.Parameter{
+		queryParam("limit", fmt.Sprintf("Page size, default %d; larger values are capped at %d.",
+			repositories.DefaultPageLimit, repositories.MaxPageLimit),
+			&openapi.Schema{Type: "integer", Minimum: &one}),
+		queryParam("cursor", "Opaque next_cursor from the previous page.", &openapi.Schema{Type: "string"}),
+		queryParam("sort", "Sort field; prefix with - for descending.", &openapi.Schema{Type: "string", Enum: sortEnum}),
+		queryParam("include_total", "Also count all matching records.", &openapi.Schema{Type: "boolean"}),
+		includeDeletedParam,
+	}
+	page := entityResponse("A page of "+tag+"; with Accept: text/csv or application/x-ndjson, "+
+		"every matching record streamed as an export", schema+"Page")
+	page.Content[csvType] = &openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}
+	line := openapi.Ref(schema)
+	line.Description = "one " + schema + " per line"
+	page.Content[ndjsonType] = &openapi.MediaType{Schema: line}
+
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     "List " + tag,
+		Tags:        []string{tag},
+		Parameters:  append(params, filters...),
+		Responses: responses(map[int]*openapi.Response{
+			http.StatusOK: page,
+		}, http.StatusBadRequest, http.StatusNotAcceptable),
+	}
+}
+
+func createOp(id, tag, schema string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     "Create a " + schema,
+		Description: "A client-supplied uuid makes the request an upsert: an existing record is overwritten and answered with 200.",
+		Tags:        []string{tag},
+		Parameters:  []*openapi.Parameter{idempotencyKeyParam},
+		RequestBody: jsonBody(schema),
+		Responses: responses(map[int]*openapi.Response{
+			http.StatusCreated: entityResponse("Created", schema, "Location", "ETag"),
+			http.StatusOK:      entityResponse("Upserted over an existing record", schema, "ETag"),
+		}, http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge,
+			http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity),
+	}
+}
+
+func getOp(id, tag, schema string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     "Get a " + schema,
+		Tags:        []string{tag},
+		Parameters:  []*openapi.Parameter{idParam, includeDeletedParam, ifNoneMatchParam},
+		Responses: responses(map[int]*openapi.Response{
+			http.StatusOK:          entityResponse("Found", schema, "ETag"),
+			http.StatusNotModified: {Description: "Not modified", Headers: map[string]*openapi.Header{"ETag": {Schema: &openapi.Schema{Type: "string"}}}},
+		}, http.StatusBadRequest, http.StatusNotFound),
+	}
+}
+
+func updateOp(id, tag, schema string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     "Replace a " + schema,
+		Tags:        []string{tag},
+		Parameters:  []*openapi.Parameter{idParam, ifMatchParam},
+		RequestBody: jsonBody(schema),
+		Responses: responses(map[int]*openapi.Response{
+			http.StatusOK: entityResponse("Updated", schema, "ETag"),
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed,
+			http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType,
+			http.StatusUnprocessableEntity, http.StatusPreconditionRequired),
+	}
+}
+
+func patchOp(id, tag, schema string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     "Patch a " + schema,
+		Description: "Accepts a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902).",
+		Tags:        []string{tag},
+		Parameters:  []*openapi.Parameter{idParam, ifMatchParam},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]*openapi.MediaType{
+				mergePatchType:     {Schema: openapi.Ref(schema + "Patch")},
+				"application/json": {Schema: openapi.Ref(schema + "Patch")},
+				jsonPatchType:      {Schema: openapi.Ref("JSONPatch")},
+			},
+		},
+		Responses: responses(map[int]*openapi.Response{
+			http.StatusOK: entityResponse("Patched", schema, "ETag"),
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed,
+			http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType,
+			http.StatusUnprocessableEntity, http.StatusPreconditionRequired),
+	}
+}
+
+func deleteOp(id, tag, entity string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     "Move a " + entity + " to the trash",
+		Tags:        []string{tag},
+		Parameters:  []*openapi.Parameter{idParam, ifMatchParam},
+		Responses: responses(map[int]*openapi.Response{
+			http.StatusNoContent: {Description: "Deleted"},
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired),
+	}
+}
+
+func restoreOp(id, tag, schema string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     "Restore a " + schema + " from the trash",
+		Tags:        []string{tag},
+		Parameters:  []*openapi.Parameter{idParam},
+		Responses: responses(map[int]*openapi.Response{
+			http.StatusOK: entityResponse("Restored", schema, "ETag"),
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+	}
+}
+
+func purgeOp(id, entity string) *openapi.Operation {
+	return &openapi.Operation{
+		OperationID: id,
+		Summary:     "Permanently delete a " + entity,
+		Tags:        []string{"admin"},
+		Parameters:  []*openapi.Parameter{idParam},
+		Security:    []map[string][]string{{"adminToken": {}}},
+		Responses: responses(map[int]*openapi.Response{
+			http.StatusNoContent: {Description: "Purged"},
+		}, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound),
+	}
+}
+
+func importOp() *openapi.Operation {
+	line := openapi.Ref("Product")
+	line.Description = "one Product per line"
+	resp := responses(map[int]*openapi.Response{
+		http.StatusOK: entityResponse("Per-row report", "ImportReport"),
+	}, http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge,
+		http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity)
+	// A file or store failing part way answers with the committed rows
+	for _, status := range []string{"400", "413", "500"} {
+		resp[status].Content[problem.ContentType] = &openapi.MediaType{Schema: openapi.Ref("ImportProblem")}
+	}
+
+	return &openapi.Operation{
+		OperationID: "importProducts",
+		Summary:     "Import products from CSV or NDJSON",
+		Description: "Rows are upserted by uuid, or by name when no uuid is given. " +
+			"A CSV file needs a header row naming uuid, name and price; export columns are ignored.",
+		Tags: []string{"products"},
+		Parameters: []*openapi.Parameter{
+			queryParam("dry_run", "Validate and report without writing anything.", &openapi.Schema{Type: "boolean"}),
+			idempotencyKeyParam,
+		},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]*openapi.MediaType{
+				csvType:    {Schema: &openapi.Schema{Type: "string"}},
+				ndjsonType: {Schema: line},
+			},
+		},
+		Responses: resp,
+	}
+}
+
+func batchOp() *openapi.Operation {
+	resp := responses(map[int]*openapi.Response{
+		http.StatusOK: entityResponse("Every operation succeeded and was committed", "BatchResponse"),
+	}, http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge,
+		http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity)
+
+	// A failed operation answers 422, or 500 when it failed on the server,
+	// with the results so far; invalid batches get a problem as usual.
+	for _, status := range []string{"422", "500"} {
+		resp[status].Description += "; or an operation failed and nothing was committed"
+		resp[status].Content["application/json"] = &openapi.MediaType{Schema: openapi.Ref("BatchResponse")}
+	}
+
+	return &openapi.Operation{
+		OperationID: "batch",
+		Summary:     "Run user and product operations in one transaction",
+		Description: "Operations run in order, each answered as if sent on its own. The first one " +
+			"failing rolls back the whole batch, including its audit and notification rows.",
+		Tags:        []string{"batch"},
+		Parameters:  []*openapi.Parameter{idempotencyKeyParam},
+		RequestBody: jsonBody("BatchRequest"),
+		Responses:   resp,
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go-demo/pkg/problem"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Media types accepted by PATCH. Plain application/json is treated as a
+// merge patch for clients written before the patch formats were supported.
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// acceptPatch is advertised through the Accept-Patch header (RFC 5789).
+var acceptPatch = strings.Join([]string{mergePatchType, jsonPatchType}, ", ")
+
+// applyPatch applies the request body to current, using RFC 7396 or RFC
+// 6902 semantics depending on the Content-Type, and strictly decodes the
+// result into dst. It returns the names of the top-level fields whose value
+// changed; changing any of readOnly is rejected.
+func applyPatch(w http.ResponseWriter, r *http.Request, current, dst any, readOnly ...string) ([]string, *problem.Problem) {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mt = ""
+	}
+	if mt != mergePatchType && mt != jsonPatchType && mt != "application/json" {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		return nil, &problem.Problem{
+			Type:   problem.TypeUnsupported,
+			Title:  "Unsupported media type",
+			Status: http.StatusUnsupportedMediaType,
+			Detail: "PATCH accepts " + acceptPatch,
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		return nil, decodeProblem(err)
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, malformed("request body must not be empty")
+	}
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, problem.New(http.StatusInternalServerError, "")
+	}
+
+	var patched []byte
+	if mt == jsonPatchType {
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return nil, malformed("request body is not a valid JSON Patch document")
+		}
+		patched, err = patch.Apply(original)
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			return nil, problem.New(http.StatusConflict, "a JSON Patch test operation failed")
+		case err != nil:
+			return nil, problem.Newf(http.StatusUnprocessableEntity, "JSON Patch could not be applied: %v", err)
+		}
+	} else {
+		if !json.Valid(body) || body[0] != '{' {
+			return nil, malformed("merge patch must be a JSON object")
+		}
+		patched, err = jsonpatch.MergePatch(original, body)
+		if err != nil {
+			return nil, malformed("merge patch could not be applied")
+		}
+	}
+
+	changed, err := changedFields(original, patched)
+	if err != nil {
+		return nil, malformed("patched document is not a JSON object")
+	}
+	for _, f := range changed {
+		for _, ro := range readOnly {
+			if f == ro {
+				return nil, problem.Newf(http.StatusUnprocessableEntity, "field %q is read-only", f)
+			}
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return nil, decodeProblem(err)
+	}
+	return changed, nil
+}
+
+// changedFields compares two JSON objects and returns the sorted keys whose
+// values differ, including keys present in only one of them.
+func changedFields(before, after []byte) ([]string, error) {
+	var a, b map[string]any
+	if err := json.Unmarshal(before, &a); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(after, &b); err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for k, v := range b {
+		if old, ok := a[k]; !ok || !reflect.DeepEqual(old, v) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// patchMessage is the audit message for a patch that changed fields.
+func patchMessage(entity string, changed []string) string {
+	return fmt.Sprintf("patched %s (changed: %s)", entity, strings.Join(changed, ", "))
+}
@@ -1,108 +1,443 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"go-demo/models"
+	"go-demo/pkg/apiversion"
+	"go-demo/pkg/problem"
 	uuidpkg "go-demo/pkg/uuid"
 	"go-demo/pkg/validator"
 	"go-demo/repositories"
 	"go-demo/worker"
+
+	"gorm.io/gorm"
 )
 
-func ProductHandler(w http.ResponseWriter, r *http.Request) {
+// ProductHandler dispatches on method for callers that mount the product resource
+// without method patterns. Item operations read the id from the {id} path
+// wildcard, falling back to the legacy ?id= query parameter.
+func (s *Server) ProductHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
-
 	case http.MethodGet:
-		products, err := repositories.GetProducts()
+		if r.PathValue("id") != "" {
+			s.GetProduct(w, r)
+			return
+		}
+		s.ListProducts(w, r)
+	case http.MethodPost:
+		s.CreateProduct(w, r)
+	case http.MethodPut:
+		s.UpdateProduct(w, r)
+	case http.MethodPatch:
+		s.PatchProduct(w, r)
+	case http.MethodDelete:
+		s.DeleteProduct(w, r)
+	default:
+		problem.Error(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// ListProducts handles GET /products. It supports keyset pagination (limit,
+// cursor, include_total), include_deleted, sorting and the price_gte, name_contains and
+// created_after filters. With Accept: text/csv or application/x-ndjson the
+// matching products are streamed as an export instead of a page.
+func (s *Server) ListProducts(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiateList(r)
+	if !ok {
+		notAcceptable(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	if err := checkQueryParams(query, "price_gte", "name_contains", "created_after"); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	params, err := parseListParams(query)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	createdAfter, err := parseTimeParam(query, "created_after")
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	includeDeleted, err := parseIncludeDeleted(query)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	productQuery := repositories.ProductQuery{
+		ListParams:     params,
+		NameContains:   query.Get("name_contains"),
+		CreatedAfter:   createdAfter,
+		IncludeDeleted: includeDeleted,
+	}
+	if s := query.Get("price_gte"); s != "" {
+		price, err := strconv.ParseFloat(s, 64)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			problem.Error(w, r, http.StatusBadRequest, "invalid price_gte")
 			return
 		}
-		json.NewEncoder(w).Encode(products)
+		productQuery.PriceGTE = &price
+	}
 
-		worker.Publish(worker.NewEvent(
+	if mediaType != "application/json" {
+		exportList(w, r, "product", mediaType, productCSVHeader, productCSVRecord,
+			func(fn func(models.Product) error) error {
+				return s.Products.Stream(r.Context(), productQuery, fn)
+			})
+		s.auditDetached(r, worker.NewEvent(
 			"READ",
 			"product",
-			0,
-			"listed products",
+			"",
+			"exported products as "+mediaType,
 		))
+		return
+	}
 
-	case http.MethodPost:
-		var product models.Product
-		json.NewDecoder(r.Body).Decode(&product)
+	page, err := s.Products.List(r.Context(), productQuery)
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+
+	s.auditDetached(r, worker.NewEvent(
+		"READ",
+		"product",
+		"",
+		"listed products",
+	))
+}
+
+// GetProduct handles GET /products/{id}; ?include_deleted=true also finds a
+// soft-deleted product.
+func (s *Server) GetProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	includeDeleted, err := parseIncludeDeleted(r.URL.Query())
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		// assign UUID for the new product
+	get := s.Products.Get
+	if includeDeleted {
+		get = s.Products.GetIncludingDeleted
+	}
+	product, err := get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	w.Header().Set("ETag", etag(product.Version))
+	if notModified(r, product.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, product)
+
+	s.auditDetached(r, worker.NewEvent(
+		"READ",
+		"product",
+		id,
+		"read product",
+	))
+}
+
+// CreateProduct handles POST /products. A client-supplied uuid makes the request an
+// upsert: an existing product with that UUID is overwritten and answered with 200
+// instead of a new product being created with 201.
+func (s *Server) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	var product models.Product
+	if p := decodeJSON(w, r, &product); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	// server-managed fields are never taken from the client
+	product.Version = 0
+	product.CreatedAt = time.Time{}
+	product.DeletedAt = gorm.DeletedAt{}
+
+	// assign a UUID unless the client chose one
+	clientUUID := product.UUID != ""
+	if !clientUUID {
 		product.UUID = uuidpkg.New()
+	}
 
-		if err := validator.Validate.Struct(product); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+	if err := validator.Validate.Struct(product); err != nil {
+		problem.Write(w, r, problem.FromValidation(err))
+		return
+	}
+	product.UUID = strings.ToLower(product.UUID)
 
-		if err := repositories.CreateProduct(product); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	var saved models.Product
+	created := true
+	err := s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if clientUUID {
+			saved, created, err = s.Products.Upsert(ctx, product)
+		} else {
+			saved, err = s.Products.Create(ctx, product)
+		}
+		if err != nil {
+			return err
 		}
-		w.WriteHeader(http.StatusCreated)
 
-		worker.Publish(worker.NewEvent(
+		if !created {
+			return s.Audit.Publish(ctx, worker.NewEvent(
+				"UPDATE",
+				"product",
+				saved.UUID,
+				"upserted product",
+			))
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
 			"CREATE",
 			"product",
-			0,
+			saved.UUID,
 			"created product",
 		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	w.Header().Set("ETag", etag(saved.Version))
 
-	case http.MethodPut:
-		idStr := r.URL.Query().Get("id")
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			http.Error(w, "invalid product id", http.StatusBadRequest)
-			return
-		}
+	if !created {
+		writeJSON(w, http.StatusOK, saved)
+		return
+	}
+	w.Header().Set("Location", apiversion.Path(r, "/products/"+saved.UUID))
+	writeJSON(w, http.StatusCreated, saved)
+}
 
-		var product models.Product
-		json.NewDecoder(r.Body).Decode(&product)
+// UpdateProduct handles PUT /products/{id}; the body replaces the product.
+func (s *Server) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		if err := validator.Validate.Struct(product); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+	version, p, err := s.ifMatchVersion(r, func() (int, error) {
+		current, err := s.Products.Get(r.Context(), id)
+		return current.Version, err
+	})
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	if p != nil {
+		problem.Write(w, r, p)
+		return
+	}
 
-		if err := repositories.UpdateProduct(id, product); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
+	var product models.Product
+	if p := decodeJSON(w, r, &product); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
 
-		worker.Publish(worker.NewEvent(
+	if err := validator.Validate.Struct(product); err != nil {
+		problem.Write(w, r, problem.FromValidation(err))
+		return
+	}
+
+	var updated models.Product
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if updated, err = s.Products.Update(ctx, id, product, version); err != nil {
+			return err
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
 			"UPDATE",
 			"product",
 			id,
 			"updated product",
 		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated.Version))
+	writeJSON(w, http.StatusOK, updated)
+}
 
-	case http.MethodDelete:
-		idStr := r.URL.Query().Get("id")
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			http.Error(w, "invalid product id", http.StatusBadRequest)
-			return
-		}
+// PatchProduct handles PATCH /products/{id}. The body is a JSON Merge Patch or
+// a JSON Patch applied to the stored product; only changed columns are written.
+func (s *Server) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		if err := repositories.DeleteProduct(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	product, err := s.Products.Get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+
+	// The patch is applied to the version just read, so the write is always
+	// conditional on it even without If-Match.
+	version, p, err := s.ifMatchVersion(r, func() (int, error) { return product.Version, nil })
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	if p == nil && version > 0 && version != product.Version {
+		p = preconditionFailed()
+	}
+	if p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+	version = product.Version
+
+	var patched models.Product
+	changed, p := applyPatch(w, r, product, &patched, "uuid", "version", "created_at", "deleted_at")
+	if p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	if err := validator.Validate.Struct(patched); err != nil {
+		problem.Write(w, r, problem.FromValidation(err))
+		return
+	}
+
+	var updated models.Product
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if updated, err = s.Products.Patch(ctx, id, patched, changed, version); err != nil {
+			return err
 		}
-		w.WriteHeader(http.StatusNoContent)
+		if len(changed) == 0 {
+			return nil
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
+			"UPDATE",
+			"product",
+			id,
+			patchMessage("product", changed),
+		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated.Version))
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteProduct handles DELETE /products/{id}. The product is soft-deleted and
+// can be restored until it is purged.
+func (s *Server) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, p, err := s.ifMatchVersion(r, func() (int, error) {
+		current, err := s.Products.Get(r.Context(), id)
+		return current.Version, err
+	})
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	if p != nil {
+		problem.Write(w, r, p)
+		return
+	}
 
-		worker.Publish(worker.NewEvent(
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := s.Products.Delete(ctx, id, version); err != nil {
+			return err
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
 			"DELETE",
 			"product",
 			id,
 			"deleted product",
 		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreProduct handles POST /products/{id}/restore.
+func (s *Server) RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var restored models.Product
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if restored, err = s.Products.Restore(ctx, id); err != nil {
+			return err
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
+			"RESTORE",
+			"product",
+			id,
+			"restored product",
+		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
+	}
+	w.Header().Set("ETag", etag(restored.Version))
+	writeJSON(w, http.StatusOK, restored)
+}
+
+// PurgeProduct handles DELETE /admin/products/{id}, permanently removing a
+// soft-deleted product.
+func (s *Server) PurgeProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := s.Products.Purge(ctx, id); err != nil {
+			return err
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
+			"PURGE",
+			"product",
+			id,
+			"purged product",
+		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "product", err)
+		return
 	}
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-demo/pkg/openapi"
+	uuidpkg "go-demo/pkg/uuid"
+	"go-demo/repositories"
+)
+
+// route is one endpoint: its ServeMux pattern, handler and the OpenAPI
+// operation documenting it. The same table drives RegisterRoutes and Spec,
+// so the documented contract cannot list a route the mux does not serve.
+type route struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+	op      *openapi.Operation
+}
+
+func (s *Server) routes() []route {
+	return []route{
+		{"GET", "/users", s.ListUsers, listOp("listUsers", "users", "User", repositories.UserSortKeys(),
+			queryParam("role", "Only users with this role.", &openapi.Schema{Type: "string"}),
+			nameContainsParam, createdAfterParam)},
+		{"POST", "/users", s.CreateUser, createOp("createUser", "users", "User")},
+		{"GET", "/users/{id}", s.GetUser, getOp("getUser", "users", "User")},
+		{"PUT", "/users/{id}", s.UpdateUser, updateOp("updateUser", "users", "User")},
+		{"PATCH", "/users/{id}", s.PatchUser, patchOp("patchUser", "users", "User")},
+		{"DELETE", "/users/{id}", s.DeleteUser, deleteOp("deleteUser", "users", "user")},
+		{"POST", "/users/{id}/restore", s.RestoreUser, restoreOp("restoreUser", "users", "User")},
+
+		{"GET", "/products", s.ListProducts, listOp("listProducts", "products", "Product", repositories.ProductSortKeys(),
+			queryParam("price_gte", "Only products priced at or above this value.", &openapi.Schema{Type: "number"}),
+			nameContainsParam, createdAfterParam)},
+		{"POST", "/products", s.CreateProduct, createOp("createProduct", "products", "Product")},
+		{"POST", "/products/import", s.ImportProducts, importOp()},
+		{"GET", "/products/{id}", s.GetProduct, getOp("getProduct", "products", "Product")},
+		{"PUT", "/products/{id}", s.UpdateProduct, updateOp("updateProduct", "products", "Product")},
+		{"PATCH", "/products/{id}", s.PatchProduct, patchOp("patchProduct", "products", "Product")},
+		{"DELETE", "/products/{id}", s.DeleteProduct, deleteOp("deleteProduct", "products", "product")},
+		{"POST", "/products/{id}/restore", s.RestoreProduct, restoreOp("restoreProduct", "products", "Product")},
+
+		{"POST", "/batch", s.Batch, batchOp()},
+
+		{"DELETE", "/admin/users/{id}", s.adminOnly(s.PurgeUser), purgeOp("purgeUser", "user")},
+		{"DELETE", "/admin/products/{id}", s.adminOnly(s.PurgeProduct), purgeOp("purgeProduct", "product")},
+	}
+}
+
+// RegisterRoutes mounts the user and product resources, the admin routes
+// and the API documentation on mux using method and wildcard patterns.
+func (s *Server) RegisterRoutes(mux *http.ServeMux) {
+	for _, rt := range s.routes() {
+		mux.HandleFunc(rt.method+" "+rt.path, rt.handler)
+	}
+
+	mux.HandleFunc("GET /openapi.json", ServeSpec)
+	mux.HandleFunc("GET /docs", openapi.DocsHandler(specTitle, "openapi.json"))
+}
+
+// pathUUID reads the {id} path wildcard, falling back to the ?id= query
+// parameter used before path-based routes existed. Resources are addressed
+// by their public UUID, never by the serial id; any spelling of it is
+// turned into the canonical one stored.
+func pathUUID(r *http.Request) (string, error) {
+	raw := r.PathValue("id")
+	if raw == "" {
+		raw = r.URL.Query().Get("id")
+	}
+	id, ok := uuidpkg.Canonical(raw)
+	if !ok {
+		return "", fmt.Errorf("invalid id %q: expected a UUID", raw)
+	}
+	return id, nil
+}
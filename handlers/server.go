@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+
+	"go-demo/repositories"
+)
+
+// Server serves the API from the repositories it is built with. Its
+// options are set once, before it starts serving.
+type Server struct {
+	repositories.Store
+
+	// RequireIfMatch makes PUT, PATCH and DELETE fail with 428 when the
+	// client sends no If-Match header.
+	RequireIfMatch bool
+	// AdminToken guards the admin routes; requests must send it as a
+	// bearer token. Admin routes answer 404 while it is empty.
+	AdminToken string
+
+	batchOnce sync.Once
+	batchMux  *http.ServeMux
+}
+
+// NewServer returns a Server using store for all persistence.
+func NewServer(store repositories.Store) *Server {
+	return &Server{Store: store}
+}
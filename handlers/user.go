@@ -1,123 +1,452 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
 	"go-demo/models"
+	"go-demo/pkg/apiversion"
+	"go-demo/pkg/problem"
 	uuidpkg "go-demo/pkg/uuid"
 	"go-demo/pkg/validator"
 	"go-demo/repositories"
 	"go-demo/worker"
+
+	"gorm.io/gorm"
 )
 
-func UserHandler(w http.ResponseWriter, r *http.Request) {
+// UserHandler dispatches on method for callers that mount the user resource
+// without method patterns. Item operations read the id from the {id} path
+// wildcard, falling back to the legacy ?id= query parameter.
+func (s *Server) UserHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
-
 	case http.MethodGet:
-		users, err := repositories.GetUsers()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if r.PathValue("id") != "" {
+			s.GetUser(w, r)
 			return
 		}
-		json.NewEncoder(w).Encode(users)
+		s.ListUsers(w, r)
+	case http.MethodPost:
+		s.CreateUser(w, r)
+	case http.MethodPut:
+		s.UpdateUser(w, r)
+	case http.MethodPatch:
+		s.PatchUser(w, r)
+	case http.MethodDelete:
+		s.DeleteUser(w, r)
+	default:
+		problem.Error(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
 
-		// fire‑and‑forget audit log; API response is not blocked
-		worker.Publish(worker.NewEvent(
+// ListUsers handles GET /users. It supports keyset pagination (limit,
+// cursor, include_total), include_deleted, sorting and the role, name_contains and
+// created_after filters. With Accept: text/csv or application/x-ndjson the
+// matching users are streamed as an export instead of a page.
+func (s *Server) ListUsers(w http.ResponseWriter, r *http.Request) {
+	mediaType, ok := negotiateList(r)
+	if !ok {
+		notAcceptable(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	if err := checkQueryParams(query, "role", "name_contains", "created_after"); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	params, err := parseListParams(query)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	createdAfter, err := parseTimeParam(query, "created_after")
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	includeDeleted, err := parseIncludeDeleted(query)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userQuery := repositories.UserQuery{
+		ListParams:     params,
+		Role:           query.Get("role"),
+		NameContains:   query.Get("name_contains"),
+		CreatedAfter:   createdAfter,
+		IncludeDeleted: includeDeleted,
+	}
+
+	if mediaType != "application/json" {
+		exportList(w, r, "user", mediaType, userCSVHeader, userCSVRecord,
+			func(fn func(models.User) error) error {
+				return s.Users.Stream(r.Context(), userQuery, fn)
+			})
+		s.auditDetached(r, worker.NewEvent(
 			"READ",
 			"user",
-			0,
-			"listed users",
+			"",
+			"exported users as "+mediaType,
 		))
+		return
+	}
 
-	case http.MethodPost:
-		var user models.User
-		json.NewDecoder(r.Body).Decode(&user)
+	page, err := s.Users.List(r.Context(), userQuery)
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+
+	s.auditDetached(r, worker.NewEvent(
+		"READ",
+		"user",
+		"",
+		"listed users",
+	))
+}
+
+// GetUser handles GET /users/{id}; ?include_deleted=true also finds a
+// soft-deleted user.
+func (s *Server) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	includeDeleted, err := parseIncludeDeleted(r.URL.Query())
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		// assign a UUID for this new user
+	get := s.Users.Get
+	if includeDeleted {
+		get = s.Users.GetIncludingDeleted
+	}
+	user, err := get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	w.Header().Set("ETag", etag(user.Version))
+	if notModified(r, user.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+
+	s.auditDetached(r, worker.NewEvent(
+		"READ",
+		"user",
+		id,
+		"read user",
+	))
+}
+
+// CreateUser handles POST /users. A client-supplied uuid makes the request an
+// upsert: an existing user with that UUID is overwritten and answered with 200
+// instead of a new user being created with 201.
+func (s *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if p := decodeJSON(w, r, &user); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	// server-managed fields are never taken from the client
+	user.Version = 0
+	user.CreatedAt = time.Time{}
+	user.DeletedAt = gorm.DeletedAt{}
+
+	// assign a UUID unless the client chose one
+	clientUUID := user.UUID != ""
+	if !clientUUID {
 		user.UUID = uuidpkg.New()
+	}
 
-		if err := validator.Validate.Struct(user); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+	if err := validator.Validate.Struct(user); err != nil {
+		problem.Write(w, r, problem.FromValidation(err))
+		return
+	}
+	user.UUID = strings.ToLower(user.UUID)
 
-		if err := repositories.CreateUser(user); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	var saved models.User
+	created := true
+	err := s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if clientUUID {
+			saved, created, err = s.Users.Upsert(ctx, user)
+		} else {
+			saved, err = s.Users.Create(ctx, user)
+		}
+		if err != nil {
+			return err
 		}
-		w.WriteHeader(http.StatusCreated)
 
-		worker.Publish(worker.NewEvent(
+		if !created {
+			return s.Audit.Publish(ctx, worker.NewEvent(
+				"UPDATE",
+				"user",
+				saved.UUID,
+				"upserted user",
+			))
+		}
+		if err := s.Audit.Publish(ctx, worker.NewEvent(
 			"CREATE",
 			"user",
-			0,
+			saved.UUID,
 			"created user",
-		))
-
-		// enqueue welcome email notification asynchronously
-		// API response is not blocked by notification processing
-		// enqueue welcome email notification asynchronously using Outbox Pattern
-		// API response is not blocked by notification processing
-		// Payload: JSON
-		recipient := user.Name + "@example.com"
-		payloadMap := map[string]string{
-			"recipient": recipient,
-			"message":   "Welcome to our platform, " + user.Name + "!",
+		)); err != nil {
+			return err
 		}
-		payloadBytes, _ := json.Marshal(payloadMap)
 
-		repositories.CreateNotificationOutbox("WELCOME_EMAIL", string(payloadBytes))
+		// enqueue the welcome email through the outbox; it commits with the
+		// user, so no user exists without one and no email is sent for a
+		// user that was rolled back
+		return s.Outbox.Enqueue(ctx, "WELCOME_EMAIL", saved.UUID, welcomePayload(user.Name))
+	})
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	w.Header().Set("ETag", etag(saved.Version))
 
-	case http.MethodPut:
-		idStr := r.URL.Query().Get("id")
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			http.Error(w, "invalid user id", http.StatusBadRequest)
-			return
-		}
+	if !created {
+		writeJSON(w, http.StatusOK, saved)
+		return
+	}
+	w.Header().Set("Location", apiversion.Path(r, "/users/"+saved.UUID))
+	writeJSON(w, http.StatusCreated, saved)
+}
 
-		var user models.User
-		json.NewDecoder(r.Body).Decode(&user)
+// welcomePayload is the JSON outbox payload of the welcome email for name.
+func welcomePayload(name string) string {
+	payload, _ := json.Marshal(map[string]string{
+		"recipient": name + "@example.com",
+		"message":   "Welcome to our platform, " + name + "!",
+	})
+	return string(payload)
+}
 
-		if err := validator.Validate.Struct(user); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+// UpdateUser handles PUT /users/{id}; the body replaces the user.
+func (s *Server) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		if err := repositories.UpdateUser(id, user); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
+	version, p, err := s.ifMatchVersion(r, func() (int, error) {
+		current, err := s.Users.Get(r.Context(), id)
+		return current.Version, err
+	})
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	if p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	var user models.User
+	if p := decodeJSON(w, r, &user); p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	if err := validator.Validate.Struct(user); err != nil {
+		problem.Write(w, r, problem.FromValidation(err))
+		return
+	}
 
-		worker.Publish(worker.NewEvent(
+	var updated models.User
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if updated, err = s.Users.Update(ctx, id, user, version); err != nil {
+			return err
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
 			"UPDATE",
 			"user",
 			id,
 			"updated user",
 		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated.Version))
+	writeJSON(w, http.StatusOK, updated)
+}
 
-	case http.MethodDelete:
-		idStr := r.URL.Query().Get("id")
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			http.Error(w, "invalid user id", http.StatusBadRequest)
-			return
-		}
+// PatchUser handles PATCH /users/{id}. The body is a JSON Merge Patch or
+// a JSON Patch applied to the stored user; only changed columns are written.
+func (s *Server) PatchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		if err := repositories.DeleteUser(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	user, err := s.Users.Get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+
+	// The patch is applied to the version just read, so the write is always
+	// conditional on it even without If-Match.
+	version, p, err := s.ifMatchVersion(r, func() (int, error) { return user.Version, nil })
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	if p == nil && version > 0 && version != user.Version {
+		p = preconditionFailed()
+	}
+	if p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+	version = user.Version
+
+	var patched models.User
+	changed, p := applyPatch(w, r, user, &patched, "uuid", "version", "created_at", "deleted_at")
+	if p != nil {
+		problem.Write(w, r, p)
+		return
+	}
+
+	if err := validator.Validate.Struct(patched); err != nil {
+		problem.Write(w, r, problem.FromValidation(err))
+		return
+	}
+
+	var updated models.User
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if updated, err = s.Users.Patch(ctx, id, patched, changed, version); err != nil {
+			return err
 		}
-		w.WriteHeader(http.StatusNoContent)
+		if len(changed) == 0 {
+			return nil
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
+			"UPDATE",
+			"user",
+			id,
+			patchMessage("user", changed),
+		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated.Version))
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteUser handles DELETE /users/{id}. The user is soft-deleted and
+// can be restored until it is purged.
+func (s *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	version, p, err := s.ifMatchVersion(r, func() (int, error) {
+		current, err := s.Users.Get(r.Context(), id)
+		return current.Version, err
+	})
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	if p != nil {
+		problem.Write(w, r, p)
+		return
+	}
 
-		worker.Publish(worker.NewEvent(
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := s.Users.Delete(ctx, id, version); err != nil {
+			return err
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
 			"DELETE",
 			"user",
 			id,
 			"deleted user",
 		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser handles POST /users/{id}/restore.
+func (s *Server) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var restored models.User
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		var err error
+		if restored, err = s.Users.Restore(ctx, id); err != nil {
+			return err
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
+			"RESTORE",
+			"user",
+			id,
+			"restored user",
+		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
+	}
+	w.Header().Set("ETag", etag(restored.Version))
+	writeJSON(w, http.StatusOK, restored)
+}
+
+// PurgeUser handles DELETE /admin/users/{id}, permanently removing a
+// soft-deleted user.
+func (s *Server) PurgeUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = s.Tx.Transaction(r.Context(), func(ctx context.Context) error {
+		if err := s.Users.Purge(ctx, id); err != nil {
+			return err
+		}
+		return s.Audit.Publish(ctx, worker.NewEvent(
+			"PURGE",
+			"user",
+			id,
+			"purged user",
+		))
+	})
+	if err != nil {
+		writeRepoError(w, r, "user", err)
+		return
 	}
+	w.WriteHeader(http.StatusNoContent)
 }
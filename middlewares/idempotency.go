@@ -0,0 +1,165 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go-demo/pkg/logger"
+	"go-demo/pkg/problem"
+	"go-demo/repositories"
+)
+
+// IdempotencyKeyTTL is how long a key and its stored response are kept.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	maxIdempotencyKey = 255
+	// maxIdempotentBody bounds how much of a POST body is read for the
+	// fingerprint; handlers enforce their own limits, none of them larger.
+	maxIdempotentBody = 32 << 20
+)
+
+// replayedHeaders are the response headers stored with a key and replayed.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// IdempotencyMiddleware makes POST requests carrying an Idempotency-Key
+// header safe to retry. The first request with a key runs normally and its
+// response is stored; repeats with the same body get the stored response
+// back, repeats with a different body get 422, and repeats that arrive
+// while the first is still running get 409. Keys are kept in store.
+func IdempotencyMiddleware(store repositories.IdempotencyStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			problem.Error(w, r, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest, "request body could not be read")
+			return
+		}
+		if len(body) > maxIdempotentBody {
+			problem.Write(w, r, &problem.Problem{
+				Type:   problem.TypeBodyTooLarge,
+				Title:  "Request body too large",
+				Status: http.StatusRequestEntityTooLarge,
+				Detail: fmt.Sprintf("requests with an Idempotency-Key are limited to %d bytes", maxIdempotentBody),
+			})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.New()
+		io.WriteString(sum, r.Method+" "+r.URL.Path+"\n")
+		sum.Write(body)
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+		claimed, existing, err := store.Claim(r.Context(), key, fingerprint, IdempotencyKeyTTL)
+		if err != nil {
+			logger.Log.Error().Err(err).Str("idempotency_key", key).Msg("failed to claim idempotency key")
+			problem.Error(w, r, http.StatusInternalServerError, "")
+			return
+		}
+
+		if !claimed {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				problem.Error(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+			case existing.Status != repositories.IdempotencyCompleted:
+				w.Header().Set("Retry-After", "1")
+				problem.Error(w, r, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+			default:
+				replay(w, existing.ResponseStatus, existing.ResponseHeaders, existing.ResponseBody)
+			}
+			return
+		}
+
+		// The outcome is recorded even if the client has hung up meanwhile.
+		ctx := context.WithoutCancel(r.Context())
+
+		// A panicking handler must not leave the key stuck in PROCESSING.
+		defer func() {
+			if p := recover(); p != nil {
+				store.Release(ctx, key)
+				panic(p)
+			}
+		}()
+
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		// Server errors are not stored so the client can retry them.
+		if rec.status >= http.StatusInternalServerError {
+			if err := store.Release(ctx, key); err != nil {
+				logger.Log.Error().Err(err).Str("idempotency_key", key).Msg("failed to release idempotency key")
+			}
+		} else {
+			stored := map[string]string{}
+			for _, h := range replayedHeaders {
+				if v := rec.header.Get(h); v != "" {
+					stored[h] = v
+				}
+			}
+			headers, _ := json.Marshal(stored)
+			if err := store.Complete(ctx, key, rec.status, string(headers), rec.body.Bytes()); err != nil {
+				logger.Log.Error().Err(err).Str("idempotency_key", key).Msg("failed to store idempotent response")
+			}
+		}
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// replay writes a stored response, marking it as a replay.
+func replay(w http.ResponseWriter, status int, headers string, body []byte) {
+	stored := map[string]string{}
+	json.Unmarshal([]byte(headers), &stored)
+	for k, v := range stored {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// bufferedResponse captures a handler's response so it can be stored
+// before it is sent.
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.wroteHeader {
+		return
+	}
+	b.status = status
+	b.wroteHeader = true
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
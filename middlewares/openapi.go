@@ -0,0 +1,264 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-demo/pkg/logger"
+	"go-demo/pkg/openapi"
+	"go-demo/pkg/problem"
+)
+
+const (
+	// maxValidatedBody is the largest request body the middleware reads;
+	// bigger bodies are passed on untouched for the handler to reject.
+	maxValidatedBody = 1 << 20
+	// maxCapturedResponse bounds how much of a response is kept for
+	// validation; larger responses are not checked.
+	maxCapturedResponse = 4 << 20
+)
+
+// OpenAPIValidationMiddleware rejects requests that break the contract in
+// doc before they reach next: path and query parameters, the request
+// content type and the JSON body are checked against the operation
+// serving the request. Requests for paths the document does not describe
+// pass through unchanged. With validateResponses, meant for development
+// and test, outgoing responses are checked too and contract violations
+// logged.
+func OpenAPIValidationMiddleware(doc *openapi.Document, validateResponses bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, pathParams := doc.Find(r.Method, r.URL.Path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if p := validateRequest(doc, op, pathParams, r); p != nil {
+			problem.Write(w, r, p)
+			return
+		}
+
+		if !validateResponses {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &recordingResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		validateResponse(doc, op, r, rec)
+	})
+}
+
+func validateRequest(doc *openapi.Document, op *openapi.Operation, pathParams map[string]string, r *http.Request) *problem.Problem {
+	query := r.URL.Query()
+	documented := map[string]bool{}
+
+	for _, param := range op.Parameters {
+		var raw string
+		var present bool
+		switch param.In {
+		case "path":
+			raw, present = pathParams[param.Name], true
+		case "query":
+			documented[param.Name] = true
+			_, present = query[param.Name]
+			raw = query.Get(param.Name)
+		case "header":
+			raw = r.Header.Get(param.Name)
+			present = raw != ""
+		}
+		if !present {
+			if param.Required {
+				return problem.Newf(http.StatusBadRequest, "missing %s parameter %q", param.In, param.Name)
+			}
+			continue
+		}
+		if v := doc.ValidateParam(param, raw); len(v) > 0 {
+			return problem.Newf(http.StatusBadRequest, "invalid %s parameter %q: %s", param.In, param.Name, v[0].Message)
+		}
+	}
+
+	for name := range query {
+		if !documented[name] {
+			return problem.Newf(http.StatusBadRequest, "unsupported query parameter %q", name)
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+	return validateBody(doc, op.RequestBody, r)
+}
+
+// validateBody checks the content type and JSON body of r. Bodies that are
+// empty, too large, line-based (CSV, NDJSON) or not JSON at all are left to
+// the handler, whose problem documents for those cases are already part of
+// the contract.
+func validateBody(doc *openapi.Document, rb *openapi.RequestBody, r *http.Request) *problem.Problem {
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, _ = mime.ParseMediaType(ct)
+	}
+	media, ok := rb.Content[mediaType]
+	if !ok {
+		accepted := make([]string, 0, len(rb.Content))
+		for t := range rb.Content {
+			accepted = append(accepted, t)
+		}
+		slices.Sort(accepted)
+		return &problem.Problem{
+			Type:   problem.TypeUnsupported,
+			Title:  "Unsupported media type",
+			Status: http.StatusUnsupportedMediaType,
+			Detail: "Content-Type must be one of " + strings.Join(accepted, ", "),
+		}
+	}
+
+	if !isJSON(mediaType) {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) == 0 || len(body) > maxValidatedBody {
+		return nil
+	}
+
+	violations := doc.ValidateJSON(media.Schema, body)
+	if len(violations) == 0 || violations[0].Keyword == "json" {
+		return nil
+	}
+
+	// A body of the wrong shape is malformed, as when the handler's
+	// decoder rejects it; a well-formed body breaking a constraint is a
+	// validation error.
+	for _, v := range violations {
+		if v.Keyword == "type" || v.Keyword == "additionalProperties" {
+			return &problem.Problem{
+				Type:   problem.TypeMalformedBody,
+				Title:  "Malformed request body",
+				Status: http.StatusBadRequest,
+				Detail: describe(v),
+			}
+		}
+	}
+
+	p := &problem.Problem{
+		Type:   problem.TypeValidation,
+		Title:  "Validation failed",
+		Status: http.StatusUnprocessableEntity,
+		Detail: "one or more fields are invalid",
+	}
+	for _, v := range violations {
+		p.Errors = append(p.Errors, problem.FieldError{
+			Field:   fieldName(v.Path),
+			Tag:     v.Keyword,
+			Param:   v.Param,
+			Message: v.Message,
+		})
+	}
+	return p
+}
+
+func describe(v openapi.Violation) string {
+	if v.Path == "" {
+		return "request body: " + v.Message
+	}
+	return fmt.Sprintf("field %q: %s", fieldName(v.Path), v.Message)
+}
+
+// fieldName turns a JSON pointer into the dotted field name used in
+// validation problems.
+func fieldName(pointer string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(pointer, "/"), "/", ".")
+}
+
+func validateResponse(doc *openapi.Document, op *openapi.Operation, r *http.Request, rec *recordingResponse) {
+	var violations []string
+
+	resp, ok := op.Responses[strconv.Itoa(rec.status)]
+	switch {
+	case !ok:
+		violations = append(violations, "undocumented status "+strconv.Itoa(rec.status))
+	case len(resp.Content) > 0:
+		mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		media, ok := resp.Content[mediaType]
+		if !ok {
+			violations = append(violations, "undocumented content type "+strconv.Quote(mediaType))
+			break
+		}
+		if rec.truncated || !isJSON(mediaType) {
+			break
+		}
+		for _, v := range doc.ValidateJSON(media.Schema, rec.body.Bytes()) {
+			violations = append(violations, v.String())
+		}
+	}
+
+	if len(violations) > 0 {
+		logger.Log.Warn().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Str("operation", op.OperationID).
+			Int("status", rec.status).
+			Strs("violations", violations).
+			Msg("response violates OpenAPI contract")
+	}
+}
+
+// isJSON reports whether contentType is JSON or a +json type.
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// recordingResponse passes a response through to the client while keeping
+// a copy of its status and body.
+type recordingResponse struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	truncated   bool // body was not captured in full
+}
+
+func (rec *recordingResponse) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recordingResponse) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+	if !rec.truncated && !isJSON(rec.Header().Get("Content-Type")) {
+		// Only JSON bodies are checked against schemas; exports may be huge.
+		rec.truncated = true
+	}
+	if rec.body.Len()+len(p) > maxCapturedResponse {
+		rec.truncated = true
+	} else if !rec.truncated {
+		rec.body.Write(p)
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush sends buffered data to the client, for streaming handlers.
+func (rec *recordingResponse) Flush() {
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *recordingResponse) Unwrap() http.ResponseWriter { return rec.ResponseWriter }
+
+// readCloser reads from a replayed prefix but closes the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
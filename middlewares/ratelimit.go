@@ -8,7 +8,9 @@ import (
 
 	"golang.org/x/time/rate"
 
+	"go-demo/config"
 	"go-demo/pkg/logger"
+	"go-demo/pkg/problem"
 )
 
 type client struct {
@@ -22,18 +24,23 @@ var (
 	cleanupOnce sync.Once
 )
 
-func getLimiter(ip string) *rate.Limiter {
+// getLimiter returns the limiter of ip, created or adjusted to cfg so a
+// reloaded limit applies to clients already seen.
+func getLimiter(ip string, cfg config.RateLimit) *rate.Limiter {
 	mu.Lock()
 	defer mu.Unlock()
 
 	c, ok := clients[ip]
 	if !ok {
-		// Default: 5 requests per second with burst of 10
-		l := rate.NewLimiter(5, 10)
+		l := rate.NewLimiter(rate.Limit(cfg.RPS), cfg.Burst)
 		clients[ip] = &client{limiter: l, lastSeen: time.Now()}
 		return l
 	}
 	c.lastSeen = time.Now()
+	if c.limiter.Limit() != rate.Limit(cfg.RPS) || c.limiter.Burst() != cfg.Burst {
+		c.limiter.SetLimit(rate.Limit(cfg.RPS))
+		c.limiter.SetBurst(cfg.Burst)
+	}
 	return c.limiter
 }
 
@@ -55,8 +62,10 @@ func startCleanup() {
 	})
 }
 
-// RateLimitMiddleware enforces a per-IP token-bucket limiter.
-func RateLimitMiddleware(next http.Handler) http.Handler {
+// RateLimitMiddleware enforces a per-IP token-bucket limiter of RPS
+// requests per second with bursts of Burst, as limits returns them for
+// each request.
+func RateLimitMiddleware(limits func() config.RateLimit, next http.Handler) http.Handler {
 	startCleanup()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ip, _, err := net.SplitHostPort(r.RemoteAddr)
@@ -64,10 +73,10 @@ func RateLimitMiddleware(next http.Handler) http.Handler {
 			ip = r.RemoteAddr
 		}
 
-		limiter := getLimiter(ip)
+		limiter := getLimiter(ip, limits())
 		if !limiter.Allow() {
 			logger.Log.Warn().Str("ip", ip).Msg("rate limit exceeded")
-			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			problem.Error(w, r, http.StatusTooManyRequests, "rate limit exceeded; retry later")
 			return
 		}
 
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// IdempotencyKey records the outcome of a POST sent with an Idempotency-Key
+// header so that retries are answered with the original response.
+type IdempotencyKey struct {
+	Key         string `gorm:"column:idempotency_key;primaryKey;size:255"`
+	Fingerprint string `gorm:"not null;size:64"`              // sha256 of method, path and body
+	Status      string `gorm:"not null;default:'PROCESSING'"` // PROCESSING, COMPLETED
+
+	ResponseStatus  int
+	ResponseHeaders string `gorm:"type:text"` // JSON object of replayed headers
+	ResponseBody    []byte
+
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
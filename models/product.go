@@ -1,13 +1,30 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	uuidpkg "go-demo/pkg/uuid"
+
+	"gorm.io/gorm"
+)
 
 type Product struct {
-	ID        int       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
-	UUID      string    `json:"uuid,omitempty" gorm:"type:uuid;default:gen_random_uuid();column:uuid"`
-	Name      string    `json:"name" validate:"required" gorm:"column:name;not null"`
-	Price     float64   `json:"price" validate:"required,gt=0" gorm:"column:price;not null"`
-	CreatedAt time.Time `json:"created_at,omitempty" gorm:"column:created_at;autoCreateTime"`
+	ID        int            `json:"-" gorm:"column:id;primaryKey;autoIncrement"` // internal; the API addresses products by UUID
+	UUID      string         `json:"uuid,omitempty" validate:"omitempty,uuid" gorm:"type:uuid;column:uuid;uniqueIndex:idx_products_uuid"`
+	Name      string         `json:"name" validate:"required" gorm:"column:name;not null"`
+	Price     float64        `json:"price" validate:"required,gt=0" gorm:"column:price;not null"`
+	Version   int            `json:"version" gorm:"column:version;not null;default:1" openapi:"readOnly"`
+	CreatedAt time.Time      `json:"created_at,omitempty" gorm:"column:created_at;autoCreateTime" openapi:"readOnly"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"column:deleted_at;index" openapi:"readOnly"`
+}
+
+// BeforeCreate assigns the public UUID in Go rather than relying on a
+// database default, which not every dialect has.
+func (p *Product) BeforeCreate(*gorm.DB) error {
+	if p.UUID == "" {
+		p.UUID = uuidpkg.New()
+	}
+	return nil
 }
 
 func (Product) TableName() string {
@@ -1,13 +1,30 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	uuidpkg "go-demo/pkg/uuid"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
-	ID        int       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
-	UUID      string    `json:"uuid,omitempty" gorm:"type:uuid;default:gen_random_uuid();column:uuid"`
-	Name      string    `json:"name" validate:"required" gorm:"column:name;not null"`
-	Role      string    `json:"role" validate:"required" gorm:"column:role;not null"`
-	CreatedAt time.Time `json:"created_at,omitempty" gorm:"column:created_at;autoCreateTime"`
+	ID        int            `json:"-" gorm:"column:id;primaryKey;autoIncrement"` // internal; the API addresses users by UUID
+	UUID      string         `json:"uuid,omitempty" validate:"omitempty,uuid" gorm:"type:uuid;column:uuid;uniqueIndex:idx_users_uuid"`
+	Name      string         `json:"name" validate:"required" gorm:"column:name;not null"`
+	Role      string         `json:"role" validate:"required" gorm:"column:role;not null"`
+	Version   int            `json:"version" gorm:"column:version;not null;default:1" openapi:"readOnly"`
+	CreatedAt time.Time      `json:"created_at,omitempty" gorm:"column:created_at;autoCreateTime" openapi:"readOnly"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"column:deleted_at;index" openapi:"readOnly"`
+}
+
+// BeforeCreate assigns the public UUID in Go rather than relying on a
+// database default, which not every dialect has.
+func (u *User) BeforeCreate(*gorm.DB) error {
+	if u.UUID == "" {
+		u.UUID = uuidpkg.New()
+	}
+	return nil
 }
 
 func (User) TableName() string {
@@ -0,0 +1,175 @@
+// Package apiversion mounts several versions of the API side by side on one
+// ServeMux, marks retired versions with Deprecation and Sunset headers and
+// counts requests per version so it is clear when one can be removed. The
+// counts are kept in memory: they cover one process since it started, so
+// a deployment's usage is the sum over its instances.
+package apiversion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Version is one mounted API version.
+type Version struct {
+	// Name identifies the version, e.g. "v1". Its handler is mounted under
+	// "/"+Name; the empty name mounts it at the root for unversioned paths.
+	Name string
+	// Handler serves paths relative to the version prefix.
+	Handler http.Handler
+	// Deprecated, when set, is sent as the Deprecation header (RFC 9745).
+	Deprecated time.Time
+	// Sunset, when set, is sent as the Sunset header (RFC 8594).
+	Sunset time.Time
+	// Successor names the version replacing this one, advertised in a
+	// successor-version Link.
+	Successor string
+}
+
+// Prefix is the path prefix the version is mounted under.
+func (v Version) Prefix() string {
+	if v.Name == "" {
+		return ""
+	}
+	return "/" + v.Name
+}
+
+// Stats are the request counters of one version, as seen by one instance.
+type Stats struct {
+	Version     string     `json:"version"`
+	Deprecated  bool       `json:"deprecated"`
+	Sunset      *time.Time `json:"sunset,omitempty"`
+	Requests    uint64     `json:"requests"`
+	LastRequest *time.Time `json:"last_request,omitempty"`
+	// Instance is the host that counted and Since when it started to;
+	// requests served elsewhere or before a restart are not included.
+	Instance string    `json:"instance"`
+	Since    time.Time `json:"since"`
+}
+
+type mounted struct {
+	Version
+	requests atomic.Uint64
+	last     atomic.Int64 // unix nanoseconds
+}
+
+// Router mounts versions on a ServeMux.
+type Router struct {
+	mux      *http.ServeMux
+	instance string
+	since    time.Time
+
+	mu       sync.RWMutex
+	versions []*mounted
+}
+
+// NewRouter returns a Router mounting versions on mux.
+func NewRouter(mux *http.ServeMux) *Router {
+	instance, _ := os.Hostname()
+	return &Router{mux: mux, instance: instance, since: time.Now().UTC()}
+}
+
+// Mount serves v under its prefix. Mounting two versions with the same
+// name panics, as registering a duplicate ServeMux pattern does.
+func (rt *Router) Mount(v Version) {
+	m := &mounted{Version: v}
+
+	rt.mu.Lock()
+	rt.versions = append(rt.versions, m)
+	rt.mu.Unlock()
+
+	h := rt.serve(m)
+	if v.Name == "" {
+		rt.mux.Handle("/", h)
+		return
+	}
+	rt.mux.Handle(v.Prefix()+"/", http.StripPrefix(v.Prefix(), h))
+}
+
+func (rt *Router) serve(m *mounted) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.requests.Add(1)
+		m.last.Store(time.Now().UnixNano())
+
+		if !m.Deprecated.IsZero() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(m.Deprecated.Unix(), 10))
+		}
+		if !m.Sunset.IsZero() {
+			w.Header().Set("Sunset", m.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if m.Successor != "" {
+			w.Header().Add("Link", "</"+m.Successor+`/>; rel="successor-version"`)
+		}
+
+		m.Handler.ServeHTTP(w, r.WithContext(NewContext(r.Context(), m.Version)))
+	})
+}
+
+// Stats returns the request counters of every mounted version, by name.
+// They are this process's own, approximate for a deployment of several.
+func (rt *Router) Stats() []Stats {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	out := make([]Stats, 0, len(rt.versions))
+	for _, m := range rt.versions {
+		s := Stats{
+			Version:    m.Name,
+			Deprecated: !m.Deprecated.IsZero(),
+			Requests:   m.requests.Load(),
+			Instance:   rt.instance,
+			Since:      rt.since,
+		}
+		if !m.Sunset.IsZero() {
+			sunset := m.Sunset
+			s.Sunset = &sunset
+		}
+		if ns := m.last.Load(); ns != 0 {
+			last := time.Unix(0, ns).UTC()
+			s.LastRequest = &last
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
+
+// StatsHandler serves Stats as JSON. Each instance answers with its own
+// counts; sum them across instances before deciding to retire a version.
+func (rt *Router) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rt.Stats())
+	})
+}
+
+type versionKey struct{}
+
+// NewContext returns a copy of ctx carrying v as the version serving the
+// request, for requests a handler builds itself, e.g. batch operations.
+func NewContext(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, versionKey{}, v)
+}
+
+// FromContext returns the version serving the request, if any.
+func FromContext(ctx context.Context) (Version, bool) {
+	v, ok := ctx.Value(versionKey{}).(Version)
+	return v, ok
+}
+
+// Path prefixes p, a path relative to the version, with the prefix of the
+// version serving r, so links in responses point back into that version.
+func Path(r *http.Request, p string) string {
+	v, ok := FromContext(r.Context())
+	if !ok || v.Name == "" {
+		return p
+	}
+	return v.Prefix() + p
+}
@@ -21,22 +21,26 @@ func init() {
 	zlog.Logger = l
 }
 
-// Init configures the global logger. It reads LOG_LEVEL from the environment
-// and overrides the package logger. Call this early in `main` when possible.
-func Init() {
-	level := strings.ToLower(os.Getenv("LOG_LEVEL"))
-	if level == "" {
-		level = "info"
-	}
-
+// Init configures the global logger at level (debug, info, warn, error,
+// fatal or panic) and overrides the package logger. Call this early in
+// `main` when possible.
+func Init(level string) {
 	zerolog.TimeFieldFormat = time.RFC3339
 	w := zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}
-	l := zerolog.New(w).With().Timestamp().Logger().Level(parseLevel(level))
+	// the level is applied globally so SetLevel can change it while
+	// other goroutines log
+	l := zerolog.New(w).With().Timestamp().Logger()
+	SetLevel(level)
 
 	Log = l
 	zlog.Logger = l
 }
 
+// SetLevel changes the level of every logger, safely for concurrent use.
+func SetLevel(level string) {
+	zerolog.SetGlobalLevel(parseLevel(level))
+}
+
 func parseLevel(s string) zerolog.Level {
 	switch strings.ToLower(s) {
 	case "debug":
@@ -0,0 +1,24 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+)
+
+//go:embed docs.html
+var docsPage string
+
+// DocsHandler serves a self-contained HTML page that renders the document
+// published at specURL, which may be relative to the page so the docs work
+// under any mount prefix. It loads nothing from outside the API, so it
+// works offline.
+func DocsHandler(title, specURL string) http.HandlerFunc {
+	page := strings.NewReplacer("{{TITLE}}", title, "{{SPEC_URL}}", specURL).Replace(docsPage)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+		w.Write([]byte(page))
+	}
+}
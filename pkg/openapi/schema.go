@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	// rawType holds arbitrary JSON, so its schema allows anything.
+	rawType = reflect.TypeOf(json.RawMessage{})
+)
+
+// nullTimeTypes marshal as an RFC 3339 string or null.
+var nullTimeTypes = map[string]bool{
+	"gorm.io/gorm.DeletedAt": true,
+	"database/sql.NullTime":  true,
+}
+
+// SchemaOf derives a schema from the Go value v. Struct fields are named by
+// their json tag, skipped when tagged "-", and constrained by their validate
+// tag. A field tagged openapi:"readOnly" is marked read-only. Struct schemas
+// are closed: the API rejects unknown fields, so the schema does too.
+func SchemaOf(v any) *Schema {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		s := schemaOf(t.Elem())
+		s.Type = nullable(s.Type)
+		return s
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t == rawType || t.Kind() == reflect.Interface {
+		return &Schema{}
+	}
+	if nullTimeTypes[t.PkgPath()+"."+t.Name()] {
+		return &Schema{Type: []string{"string", "null"}, Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: []string{"array", "null"}, Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func structSchema(t reflect.Type) *Schema {
+	closed := false
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: &closed}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := schemaOf(f.Type)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := schemaOf(f.Type)
+		if applyValidate(prop, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		if f.Tag.Get("openapi") == "readOnly" {
+			prop.ReadOnly = true
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// applyValidate maps go-playground validate rules onto s and reports
+// whether the field is required.
+func applyValidate(s *Schema, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+	numeric := s.Type == "integer" || s.Type == "number"
+	array := s.Items != nil
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		n, isNum := parseNum(param)
+
+		switch name {
+		case "required":
+			required = true
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, v)
+			}
+		case "gt":
+			if numeric && isNum {
+				s.ExclusiveMinimum = &n
+			} else if isNum {
+				s.MinLength = intPtr(int(n) + 1)
+			}
+		case "gte", "min":
+			if numeric && isNum {
+				s.Minimum = &n
+			} else if array && isNum {
+				s.MinItems = intPtr(int(n))
+			} else if isNum {
+				s.MinLength = intPtr(int(n))
+			}
+		case "lt":
+			if numeric && isNum {
+				s.ExclusiveMaximum = &n
+			} else if isNum {
+				s.MaxLength = intPtr(int(n) - 1)
+			}
+		case "lte", "max":
+			if numeric && isNum {
+				s.Maximum = &n
+			} else if array && isNum {
+				s.MaxItems = intPtr(int(n))
+			} else if isNum {
+				s.MaxLength = intPtr(int(n))
+			}
+		case "len":
+			if isNum && !numeric {
+				s.MinLength = intPtr(int(n))
+				s.MaxLength = intPtr(int(n))
+			}
+		}
+	}
+
+	// A required string or slice must also be non-empty and non-nil, as
+	// validator enforces.
+	if required && s.Type == "string" && s.MinLength == nil {
+		s.MinLength = intPtr(1)
+	}
+	if required && array {
+		s.Type = "array"
+	}
+	return required
+}
+
+func parseNum(s string) (float64, bool) {
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+func intPtr(n int) *int { return &n }
+
+// nullable widens a schema type to also allow null.
+func nullable(t any) any {
+	switch v := t.(type) {
+	case string:
+		return []string{v, "null"}
+	case []string:
+		for _, s := range v {
+			if s == "null" {
+				return v
+			}
+		}
+		return append(v, "null")
+	default:
+		return t
+	}
+}
@@ -0,0 +1,287 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Violation is one place where a value does not match its schema.
+type Violation struct {
+	Path    string `json:"path"`    // JSON pointer into the value
+	Keyword string `json:"keyword"` // the failing schema keyword, e.g. required
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Find returns the operation serving method and path along with the values
+// of its path parameters, or nil when the document has none.
+func (d *Document) Find(method, path string) (*Operation, map[string]string) {
+	// Literal segments win over parameters, as in http.ServeMux.
+	templates := make([]string, 0, len(d.Paths))
+	for t := range d.Paths {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.Count(templates[i], "{") < strings.Count(templates[j], "{")
+	})
+
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	for _, t := range templates {
+		op := d.Paths[t][strings.ToLower(method)]
+		if op == nil {
+			continue
+		}
+		if params, ok := matchPath(t, segs); ok {
+			return op, params
+		}
+	}
+	return nil, nil
+}
+
+func matchPath(template string, segs []string) (map[string]string, bool) {
+	tsegs := strings.Split(strings.Trim(template, "/"), "/")
+	if len(tsegs) != len(segs) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, ts := range tsegs {
+		if name, ok := strings.CutPrefix(ts, "{"); ok {
+			params[strings.TrimSuffix(name, "}")] = segs[i]
+			continue
+		}
+		if ts != segs[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Resolve follows a $ref to its component schema.
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// ValidateJSON decodes body and checks it against s.
+func (d *Document) ValidateJSON(s *Schema, body []byte) []Violation {
+	var v any
+	dec := json.NewDecoder(strings.NewReader(string(body)))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return []Violation{{Keyword: "json", Message: "invalid JSON: " + err.Error()}}
+	}
+	return d.Validate(s, v)
+}
+
+// Validate checks a decoded JSON value against s. Numbers may be float64 or
+// json.Number.
+func (d *Document) Validate(s *Schema, v any) []Violation {
+	var out []Violation
+	d.validate(s, v, "", &out)
+	return out
+}
+
+func (d *Document) validate(s *Schema, v any, path string, out *[]Violation) {
+	s = d.Resolve(s)
+	if s == nil {
+		return
+	}
+	fail := func(keyword, param, format string, args ...any) {
+		*out = append(*out, Violation{Path: path, Keyword: keyword, Param: param, Message: fmt.Sprintf(format, args...)})
+	}
+
+	kind := jsonKind(v)
+	if types := schemaTypes(s.Type); len(types) > 0 && !typeAllowed(types, kind) {
+		fail("type", strings.Join(types, " "), "expected %s, got %s", strings.Join(types, " or "), kind)
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(v) }) {
+		fail("enum", enumParam(s.Enum), "must be one of %v", s.Enum)
+	}
+
+	switch kind {
+	case "string":
+		str := v.(string)
+		n := len([]rune(str))
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("minLength", strconv.Itoa(*s.MinLength), "must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("maxLength", strconv.Itoa(*s.MaxLength), "must be at most %d characters", *s.MaxLength)
+		}
+		switch s.Format {
+		case "uuid":
+			if !uuidPattern.MatchString(str) {
+				fail("format", s.Format, "must be a UUID")
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				fail("format", s.Format, "must be an RFC 3339 date-time")
+			}
+		}
+	case "integer", "number":
+		n := toFloat(v)
+		if s.Minimum != nil && n < *s.Minimum {
+			fail("minimum", fmt.Sprint(*s.Minimum), "must be >= %v", *s.Minimum)
+		}
+		if s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum {
+			fail("exclusiveMinimum", fmt.Sprint(*s.ExclusiveMinimum), "must be > %v", *s.ExclusiveMinimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			fail("maximum", fmt.Sprint(*s.Maximum), "must be <= %v", *s.Maximum)
+		}
+		if s.ExclusiveMaximum != nil && n >= *s.ExclusiveMaximum {
+			fail("exclusiveMaximum", fmt.Sprint(*s.ExclusiveMaximum), "must be < %v", *s.ExclusiveMaximum)
+		}
+	case "array":
+		n := len(v.([]any))
+		if s.MinItems != nil && n < *s.MinItems {
+			fail("minItems", strconv.Itoa(*s.MinItems), "must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && n > *s.MaxItems {
+			fail("maxItems", strconv.Itoa(*s.MaxItems), "must have at most %d items", *s.MaxItems)
+		}
+		for i, item := range v.([]any) {
+			d.validate(s.Items, item, fmt.Sprintf("%s/%d", path, i), out)
+		}
+	case "object":
+		obj := v.(map[string]any)
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				*out = append(*out, Violation{Path: path + "/" + name, Keyword: "required", Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*out = append(*out, Violation{Path: path + "/" + name, Keyword: "additionalProperties", Message: "is not allowed"})
+				}
+				continue
+			}
+			d.validate(prop, obj[name], path+"/"+name, out)
+		}
+	}
+}
+
+func jsonKind(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := n.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if n == float64(int64(n)) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func schemaTypes(t any) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any: // a schema decoded from JSON
+		types := make([]string, 0, len(v))
+		for _, s := range v {
+			types = append(types, fmt.Sprint(s))
+		}
+		return types
+	default:
+		return nil
+	}
+}
+
+// typeAllowed applies JSON Schema's rule that an integer is also a number.
+func typeAllowed(types []string, kind string) bool {
+	return slices.Contains(types, kind) || (kind == "integer" && slices.Contains(types, "number"))
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	case float64:
+		return n
+	default:
+		return 0
+	}
+}
+
+func enumParam(enum []any) string {
+	vals := make([]string, len(enum))
+	for i, e := range enum {
+		vals[i] = fmt.Sprint(e)
+	}
+	return strings.Join(vals, " ")
+}
+
+// ValidateParam checks the raw string value of a path, query or header
+// parameter, converting it to the parameter's schema type first.
+func (d *Document) ValidateParam(p *Parameter, raw string) []Violation {
+	s := d.Resolve(p.Schema)
+	path := "/" + p.Name
+	var v any = raw
+	switch types := schemaTypes(s.Type); {
+	case slices.Contains(types, "integer"):
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return []Violation{{Path: path, Keyword: "type", Param: "integer", Message: "expected integer"}}
+		}
+		v = json.Number(strconv.FormatInt(n, 10))
+	case slices.Contains(types, "number"):
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return []Violation{{Path: path, Keyword: "type", Param: "number", Message: "expected number"}}
+		}
+		v = json.Number(raw)
+	case slices.Contains(types, "boolean"):
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []Violation{{Path: path, Keyword: "type", Param: "boolean", Message: "expected boolean"}}
+		}
+		v = b
+	}
+
+	var out []Violation
+	d.validate(s, v, path, &out)
+	return out
+}
@@ -0,0 +1,174 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-demo/pkg/apiversion"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ContentType is the media type of RFC 7807 problem documents.
+const ContentType = "application/problem+json"
+
+// Problem type URIs. Generic errors use "about:blank" so the title is the
+// status text.
+const (
+	TypeBlank         = "about:blank"
+	TypeValidation    = "/problems/validation-error"
+	TypeMalformedBody = "/problems/malformed-body"
+	TypeBodyTooLarge  = "/problems/body-too-large"
+	TypeUnsupported   = "/problems/unsupported-media-type"
+)
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	// Extensions are further members of the document, e.g. the results a
+	// request reached before it failed. They never replace the ones above.
+	Extensions map[string]any `json:"-"`
+}
+
+// FieldError describes one failed validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// New returns a generic problem for status with the given detail.
+func New(status int, detail string) *Problem {
+	return &Problem{
+		Type:   TypeBlank,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Newf is New with a formatted detail.
+func Newf(status int, format string, args ...any) *Problem {
+	return New(status, fmt.Sprintf(format, args...))
+}
+
+// Error implements error so a problem can travel through error returns.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + ": " + p.Detail
+	}
+	return p.Title
+}
+
+// MarshalJSON adds the extension members to the document.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	b, err := json.Marshal((*plain)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return b, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return nil, err
+	}
+	doc := map[string]any{}
+	for k, v := range p.Extensions {
+		doc[k] = v
+	}
+	for k, v := range members {
+		doc[k] = v
+	}
+	return json.Marshal(doc)
+}
+
+// Write sends p as the response, filling Instance from the request path.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" && r != nil {
+		p.Instance = apiversion.Path(r, r.URL.Path)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error writes a generic problem for status with the given detail.
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	Write(w, r, New(status, detail))
+}
+
+// FromValidation converts go-playground validation errors into a 422
+// problem listing each failed field. Other errors yield a plain 400.
+func FromValidation(err error) *Problem {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return New(http.StatusBadRequest, err.Error())
+	}
+
+	p := &Problem{
+		Type:   TypeValidation,
+		Title:  "Validation failed",
+		Status: http.StatusUnprocessableEntity,
+		Detail: "one or more fields are invalid",
+	}
+	for _, fe := range verrs {
+		p.Errors = append(p.Errors, FieldError{
+			Field:   fieldPath(fe),
+			Tag:     fe.Tag(),
+			Param:   fe.Param(),
+			Message: message(fe),
+		})
+	}
+	return p
+}
+
+// fieldPath drops the top-level struct name from the namespace, so
+// "User.name" becomes "name".
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "len":
+		return "must have length " + fe.Param()
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "uuid", "uuid4":
+		return "must be a valid UUID"
+	case "email":
+		return "must be a valid email address"
+	default:
+		if fe.Param() != "" {
+			return fmt.Sprintf("failed the %s=%s rule", fe.Tag(), fe.Param())
+		}
+		return fmt.Sprintf("failed the %s rule", fe.Tag())
+	}
+}
@@ -8,3 +8,21 @@ import (
 func New() string {
 	return uuid.NewString()
 }
+
+// Valid reports whether s is a well-formed UUID.
+func Valid(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+// Canonical returns s in the canonical form, lower case with dashes, and
+// whether s is a UUID at all. The other forms Valid accepts (upper case,
+// braced, urn:uuid: prefixed or without dashes) are rewritten, so every
+// UUID has one spelling.
+func Canonical(s string) (string, bool) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return "", false
+	}
+	return id.String(), true
+}
@@ -1,6 +1,9 @@
 package validator
 
 import (
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 )
 
@@ -8,6 +11,18 @@ import (
 var Validate *validator.Validate
 
 // Init initializes the validator. Call once on program startup (or tests).
+// Field names in validation errors use the json tag, so they match what
+// clients send.
 func Init() {
 	Validate = validator.New()
+	Validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
 }
@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+
+	"go-demo/database"
+	"go-demo/models"
+
+	"gorm.io/gorm"
+)
+
+type gormAudit struct{ db *gorm.DB }
+
+func (a gormAudit) Publish(ctx context.Context, ev models.AuditLog) error {
+	// outlive the request: a client hanging up must not drop the event
+	db := database.Conn(context.WithoutCancel(ctx), a.db)
+	return mapError(db.Create(&ev).Error)
+}
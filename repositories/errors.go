@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Errors returned by the repositories. Callers match them with errors.Is;
+// the underlying driver error stays wrapped for logging.
+var (
+	ErrNotFound   = errors.New("record not found")
+	ErrConflict   = errors.New("record conflicts with an existing record")
+	ErrConstraint = errors.New("record violates a constraint")
+	// ErrVersionMismatch means the record exists but its version differs
+	// from the one the caller expected.
+	ErrVersionMismatch = errors.New("record version mismatch")
+)
+
+// Postgres SQLSTATE codes from the integrity constraint violation class.
+const (
+	sqlStateNotNullViolation    = "23502"
+	sqlStateForeignKeyViolation = "23503"
+	sqlStateUniqueViolation     = "23505"
+	sqlStateCheckViolation      = "23514"
+)
+
+// sqlStater is implemented by both pgconn.PgError and pq.Error.
+type sqlStater interface {
+	SQLState() string
+}
+
+// mapError translates GORM and driver errors into the repository taxonomy.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	// Drivers that translate errors, like SQLite, report constraint
+	// violations as GORM errors instead of SQLSTATE codes.
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+	if errors.Is(err, gorm.ErrForeignKeyViolated) || errors.Is(err, gorm.ErrCheckConstraintViolated) {
+		return fmt.Errorf("%w: %w", ErrConstraint, err)
+	}
+
+	var se sqlStater
+	if errors.As(err, &se) {
+		switch se.SQLState() {
+		case sqlStateUniqueViolation:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case sqlStateForeignKeyViolation, sqlStateCheckViolation, sqlStateNotNullViolation:
+			return fmt.Errorf("%w: %w", ErrConstraint, err)
+		}
+	}
+	return err
+}
+
+// affected maps a write result to ErrNotFound when it touched no rows.
+func affected(res *gorm.DB) error {
+	if res.Error != nil {
+		return mapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// affectedVersioned is affected for writes guarded by "version = ?". When no
+// row was touched it checks, through db, whether uuid still exists to tell a
+// stale version apart from a missing record.
+func affectedVersioned(db, res *gorm.DB, model any, uuid string, version int) error {
+	err := affected(res)
+	if version == 0 || !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	var count int64
+	if err := db.Model(model).Where("uuid = ?", uuid).Count(&count).Error; err != nil {
+		return mapError(err)
+	}
+	if count > 0 {
+		return ErrVersionMismatch
+	}
+	return ErrNotFound
+}
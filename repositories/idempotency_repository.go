@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-demo/database"
+	"go-demo/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Idempotency key states.
+const (
+	IdempotencyProcessing = "PROCESSING"
+	IdempotencyCompleted  = "COMPLETED"
+)
+
+type gormIdempotency struct{ db *gorm.DB }
+
+func (s gormIdempotency) Claim(ctx context.Context, key, fingerprint string, ttl time.Duration) (bool, models.IdempotencyKey, error) {
+	db := database.Conn(ctx, s.db)
+	now := time.Now()
+	row := models.IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		Status:      IdempotencyProcessing,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+		if res.Error != nil {
+			return false, models.IdempotencyKey{}, mapError(res.Error)
+		}
+		if res.RowsAffected == 1 {
+			return true, row, nil
+		}
+
+		var existing models.IdempotencyKey
+		if err := db.Where("idempotency_key = ?", key).First(&existing).Error; err != nil {
+			return false, models.IdempotencyKey{}, mapError(err)
+		}
+		if existing.ExpiresAt.After(now) {
+			return false, existing, nil
+		}
+		if err := db.Where("idempotency_key = ? AND expires_at <= ?", key, now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return false, models.IdempotencyKey{}, mapError(err)
+		}
+	}
+	return false, models.IdempotencyKey{}, ErrConflict
+}
+
+func (s gormIdempotency) Complete(ctx context.Context, key string, status int, headers string, body []byte) error {
+	return affected(database.Conn(ctx, s.db).Model(&models.IdempotencyKey{}).Where("idempotency_key = ?", key).Updates(map[string]interface{}{
+		"status":           IdempotencyCompleted,
+		"response_status":  status,
+		"response_headers": headers,
+		"response_body":    body,
+	}))
+}
+
+func (s gormIdempotency) Release(ctx context.Context, key string) error {
+	return mapError(database.Conn(ctx, s.db).Where("idempotency_key = ?", key).Delete(&models.IdempotencyKey{}).Error)
+}
+
+func (s gormIdempotency) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res := database.Conn(ctx, s.db).Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, mapError(res.Error)
+}
@@ -0,0 +1,272 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	uuidpkg "go-demo/pkg/uuid"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPageLimit is used when a list request does not ask for a limit.
+	DefaultPageLimit = 50
+	// MaxPageLimit caps the page size a client can request.
+	MaxPageLimit = 200
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort field")
+)
+
+// ListParams describes one page of a keyset-paginated list query.
+type ListParams struct {
+	Limit        int
+	Cursor       string
+	Sort         string // column name, prefixed with "-" for descending
+	IncludeTotal bool
+}
+
+// Page is one page of results. NextCursor is empty on the last page and
+// Total is only set when the caller asked for it.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+// sortKind tells the cursor decoder how to type the stored sort value.
+type sortKind int
+
+const (
+	sortNumber sortKind = iota
+	sortString
+	sortTime
+)
+
+// cursor is the decoded form of the opaque next_cursor token. It records the
+// sort it was issued for so it cannot be replayed against a different order.
+// The public UUID breaks ties, so the token never exposes serial ids.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	UUID  string `json:"k"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, kind sortKind) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || !uuidpkg.Valid(c.UUID) {
+		return c, ErrInvalidCursor
+	}
+
+	switch kind {
+	case sortNumber:
+		if _, ok := c.Value.(float64); !ok {
+			return c, ErrInvalidCursor
+		}
+	case sortString:
+		if _, ok := c.Value.(string); !ok {
+			return c, ErrInvalidCursor
+		}
+	case sortTime:
+		str, ok := c.Value.(string)
+		if !ok {
+			return c, ErrInvalidCursor
+		}
+		t, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return c, ErrInvalidCursor
+		}
+		c.Value = t
+	}
+	return c, nil
+}
+
+// listOrder is a resolved ListParams sort: the normalized sort key, its
+// column and direction, and the cursor to resume after, if any.
+type listOrder struct {
+	sort   string
+	column string
+	desc   bool
+	after  *cursor
+}
+
+// resolveOrder checks p's sort against sorts and decodes its cursor.
+func resolveOrder(p ListParams, sorts map[string]sortKind) (listOrder, error) {
+	o := listOrder{sort: p.Sort}
+	if o.sort == "" {
+		o.sort = "created_at"
+	}
+	o.column, o.desc = strings.TrimPrefix(o.sort, "-"), strings.HasPrefix(o.sort, "-")
+	kind, ok := sorts[o.column]
+	if !ok {
+		return o, fmt.Errorf("%w: %q", ErrInvalidSort, o.column)
+	}
+
+	if p.Cursor != "" {
+		c, err := decodeCursor(p.Cursor, kind)
+		if err != nil {
+			return o, err
+		}
+		if c.Sort != o.sort {
+			return o, ErrInvalidCursor
+		}
+		o.after = &c
+	}
+	return o, nil
+}
+
+// pageLimit is p.Limit defaulted and capped.
+func pageLimit(p ListParams) int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageLimit
+	case p.Limit > MaxPageLimit:
+		return MaxPageLimit
+	}
+	return p.Limit
+}
+
+// ordered applies the requested sort to base, ordering by the sort column
+// with uuid as tie-breaker, and resumes after p.Cursor when one is given.
+func ordered(base *gorm.DB, p ListParams, sorts map[string]sortKind) (*gorm.DB, listOrder, error) {
+	o, err := resolveOrder(p, sorts)
+	if err != nil {
+		return nil, o, err
+	}
+
+	dir, op := "ASC", ">"
+	if o.desc {
+		dir, op = "DESC", "<"
+	}
+
+	q := base
+	if o.after != nil {
+		q = q.Where(fmt.Sprintf("(%s, uuid) %s (?, ?)", o.column, op), o.after.Value, o.after.UUID)
+	}
+	return q.Order(fmt.Sprintf("%s %s, uuid %s", o.column, dir, dir)), o, nil
+}
+
+// paginate runs a keyset query over base, ordering by the requested sort
+// column with uuid as tie-breaker. The sortable columns are also the json
+// keys of T, which is how the next cursor value is read back from the last
+// row. The default order is oldest first.
+func paginate[T any](base *gorm.DB, p ListParams, sorts map[string]sortKind) (Page[T], error) {
+	page := Page[T]{Items: []T{}}
+
+	base = base.Session(&gorm.Session{})
+	q, o, err := ordered(base, p, sorts)
+	if err != nil {
+		return page, err
+	}
+	limit := pageLimit(p)
+
+	if p.IncludeTotal {
+		var total int64
+		if err := base.Count(&total).Error; err != nil {
+			return page, mapError(err)
+		}
+		page.Total = &total
+	}
+
+	var items []T
+	if err := q.Limit(limit + 1).Find(&items).Error; err != nil {
+		return page, mapError(err)
+	}
+	return fillPage(page, items, limit, o)
+}
+
+// fillPage puts up to limit of items, fetched one past the limit, on page
+// and sets the next cursor when there were more.
+func fillPage[T any](page Page[T], items []T, limit int, o listOrder) (Page[T], error) {
+	if len(items) > limit {
+		items = items[:limit]
+		next, err := nextCursor(items[len(items)-1], o.sort, o.column)
+		if err != nil {
+			return page, err
+		}
+		page.NextCursor = next
+	}
+	if len(items) > 0 {
+		page.Items = items
+	}
+	return page, nil
+}
+
+// stream runs the same ordered query as paginate but hands rows to fn one
+// at a time from a database cursor instead of loading a page. Limit is
+// optional here and not capped; IncludeTotal is ignored. Returning an error
+// from fn stops the stream and is returned as is.
+func stream[T any](base *gorm.DB, p ListParams, sorts map[string]sortKind, fn func(T) error) error {
+	q, _, err := ordered(base.Session(&gorm.Session{}), p, sorts)
+	if err != nil {
+		return err
+	}
+	if p.Limit > 0 {
+		q = q.Limit(p.Limit)
+	}
+
+	rows, err := q.Rows()
+	if err != nil {
+		return mapError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item T
+		if err := q.ScanRows(rows, &item); err != nil {
+			return mapError(err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return mapError(rows.Err())
+}
+
+func nextCursor(last any, sort, column string) (string, error) {
+	b, err := json.Marshal(last)
+	if err != nil {
+		return "", err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return "", err
+	}
+	uuid, _ := fields["uuid"].(string)
+	return encodeCursor(cursor{Sort: sort, Value: fields[column], UUID: uuid}), nil
+}
+
+// containsPattern builds a LIKE pattern matching s anywhere, with LIKE
+// wildcards in s escaped. Use with `ESCAPE '\'`.
+func containsPattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(strings.ToLower(s)) + "%"
+}
+
+// sortKeys lists the accepted ?sort= values for sorts, ascending and
+// descending, in a stable order.
+func sortKeys(sorts map[string]sortKind) []string {
+	keys := make([]string, 0, 2*len(sorts))
+	for k := range sorts {
+		keys = append(keys, k, "-"+k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,208 @@
+package repositories
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go-demo/models"
+)
+
+// Memory is a Store backend kept in process memory, for tests that should
+// not need Postgres. It behaves like the GORM backend as far as callers can
+// observe: IDs, UUIDs, versions and created_at are generated the same way,
+// soft-deleted rows keep their UUID, and lists filter, sort and page alike,
+// except that strings sort bytewise rather than by database collation.
+//
+// A unit of work holds the store's lock until it ends, so units of work
+// run one at a time, and rolls back by restoring a snapshot taken when it
+// began. Nested units of work restore their own snapshot, like savepoints.
+// Inside a unit of work every call must use the ctx it was handed.
+type Memory struct {
+	mu    sync.Mutex
+	state memState
+}
+
+type memState struct {
+	users       memTable[models.User]
+	products    memTable[models.Product]
+	audit       []models.AuditLog
+	outbox      []models.NotificationOutbox
+	idempotency map[string]models.IdempotencyKey
+}
+
+// memTable holds one entity's rows in insertion order.
+type memTable[T any] struct {
+	rows   []T
+	nextID int
+}
+
+// clone copies s deeply enough that changing the copy leaves s intact;
+// rows are values, so cloning the slices and map is sufficient.
+func (s memState) clone() memState {
+	s.users.rows = slices.Clone(s.users.rows)
+	s.products.rows = slices.Clone(s.products.rows)
+	s.audit = slices.Clone(s.audit)
+	s.outbox = slices.Clone(s.outbox)
+	s.idempotency = maps.Clone(s.idempotency)
+	return s
+}
+
+// NewMemory returns an empty in-memory backend.
+func NewMemory() *Memory {
+	return &Memory{state: memState{idempotency: map[string]models.IdempotencyKey{}}}
+}
+
+// Store returns the repositories backed by m.
+func (m *Memory) Store() Store {
+	return Store{
+		Tx:          m,
+		Users:       memUsers{memRepo[models.User]{m: m, entity: userEntity}},
+		Products:    memProducts{memRepo[models.Product]{m: m, entity: productEntity}},
+		Audit:       memAudit{m},
+		Outbox:      memOutbox{m},
+		Idempotency: memIdempotency{m},
+	}
+}
+
+// AuditLogs returns a copy of every published audit event, oldest first.
+func (m *Memory) AuditLogs() []models.AuditLog {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.state.audit)
+}
+
+// NotificationOutbox returns a copy of every enqueued notification job,
+// oldest first.
+func (m *Memory) NotificationOutbox() []models.NotificationOutbox {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.state.outbox)
+}
+
+type memTxKey struct{}
+
+// inTx reports whether ctx belongs to a unit of work of m, which then
+// already holds m.mu.
+func (m *Memory) inTx(ctx context.Context) bool {
+	owner, _ := ctx.Value(memTxKey{}).(*Memory)
+	return owner == m
+}
+
+// do runs fn on the state, under the lock unless ctx's unit of work
+// already holds it. fn must leave the state unchanged when it fails.
+func (m *Memory) do(ctx context.Context, fn func(s *memState) error) error {
+	if !m.inTx(ctx) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+	return fn(&m.state)
+}
+
+// Transaction implements UnitOfWork.
+func (m *Memory) Transaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if !m.inTx(ctx) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		ctx = context.WithValue(ctx, memTxKey{}, m)
+	}
+
+	saved := m.state.clone()
+	defer func() {
+		if p := recover(); p != nil {
+			m.state = saved
+			panic(p)
+		}
+		if err != nil {
+			m.state = saved
+		}
+	}()
+	return fn(ctx)
+}
+
+type memAudit struct{ m *Memory }
+
+func (a memAudit) Publish(ctx context.Context, ev models.AuditLog) error {
+	return a.m.do(ctx, func(s *memState) error {
+		ev.ID = uint(len(s.audit) + 1)
+		s.audit = append(s.audit, ev)
+		return nil
+	})
+}
+
+type memOutbox struct{ m *Memory }
+
+func (o memOutbox) Enqueue(ctx context.Context, eventType, entityUUID, payload string) error {
+	return o.m.do(ctx, func(s *memState) error {
+		s.outbox = append(s.outbox, models.NotificationOutbox{
+			ID:         uint(len(s.outbox) + 1),
+			EventType:  eventType,
+			Payload:    payload,
+			EntityUUID: entityUUID,
+			Status:     "PENDING",
+			CreatedAt:  time.Now(),
+		})
+		return nil
+	})
+}
+
+type memIdempotency struct{ m *Memory }
+
+func (i memIdempotency) Claim(ctx context.Context, key, fingerprint string, ttl time.Duration) (claimed bool, existing models.IdempotencyKey, err error) {
+	err = i.m.do(ctx, func(s *memState) error {
+		now := time.Now()
+		if rec, ok := s.idempotency[key]; ok && rec.ExpiresAt.After(now) {
+			existing = rec
+			return nil
+		}
+		existing = models.IdempotencyKey{
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      IdempotencyProcessing,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+		}
+		s.idempotency[key] = existing
+		claimed = true
+		return nil
+	})
+	return claimed, existing, err
+}
+
+func (i memIdempotency) Complete(ctx context.Context, key string, status int, headers string, body []byte) error {
+	return i.m.do(ctx, func(s *memState) error {
+		rec, ok := s.idempotency[key]
+		if !ok {
+			return ErrNotFound
+		}
+		rec.Status = IdempotencyCompleted
+		rec.ResponseStatus = status
+		rec.ResponseHeaders = headers
+		rec.ResponseBody = slices.Clone(body)
+		s.idempotency[key] = rec
+		return nil
+	})
+}
+
+func (i memIdempotency) Release(ctx context.Context, key string) error {
+	return i.m.do(ctx, func(s *memState) error {
+		delete(s.idempotency, key)
+		return nil
+	})
+}
+
+func (i memIdempotency) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+	err := i.m.do(ctx, func(s *memState) error {
+		for key, rec := range s.idempotency {
+			if !rec.ExpiresAt.After(now) {
+				delete(s.idempotency, key)
+				deleted++
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,542 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"go-demo/models"
+	uuidpkg "go-demo/pkg/uuid"
+
+	"gorm.io/gorm"
+)
+
+// memEntity describes how the in-memory backend handles one model: where
+// its table is, its bookkeeping columns, its sortable and mutable columns.
+type memEntity[T any] struct {
+	table  func(s *memState) *memTable[T]
+	meta   func(row *T) memMeta
+	sorts  map[string]sortKind
+	column func(row T, name string) any
+	// columns returns the mutable columns of row, as the GORM backend
+	// writes them.
+	columns func(row T) map[string]interface{}
+	// assign sets the mutable columns named in cols on row.
+	assign func(row *T, cols map[string]interface{})
+}
+
+// memMeta points at the columns every entity has.
+type memMeta struct {
+	ID        *int
+	UUID      *string
+	Version   *int
+	CreatedAt *time.Time
+	DeletedAt *gorm.DeletedAt
+}
+
+var userEntity = memEntity[models.User]{
+	table: func(s *memState) *memTable[models.User] { return &s.users },
+	meta: func(u *models.User) memMeta {
+		return memMeta{&u.ID, &u.UUID, &u.Version, &u.CreatedAt, &u.DeletedAt}
+	},
+	sorts: userSorts,
+	column: func(u models.User, name string) any {
+		switch name {
+		case "name":
+			return u.Name
+		case "role":
+			return u.Role
+		}
+		return u.CreatedAt
+	},
+	columns: userColumns,
+	assign: func(u *models.User, cols map[string]interface{}) {
+		for name, v := range cols {
+			switch name {
+			case "name":
+				u.Name = v.(string)
+			case "role":
+				u.Role = v.(string)
+			}
+		}
+	},
+}
+
+var productEntity = memEntity[models.Product]{
+	table: func(s *memState) *memTable[models.Product] { return &s.products },
+	meta: func(p *models.Product) memMeta {
+		return memMeta{&p.ID, &p.UUID, &p.Version, &p.CreatedAt, &p.DeletedAt}
+	},
+	sorts: productSorts,
+	column: func(p models.Product, name string) any {
+		switch name {
+		case "name":
+			return p.Name
+		case "price":
+			return p.Price
+		}
+		return p.CreatedAt
+	},
+	columns: productColumns,
+	assign: func(p *models.Product, cols map[string]interface{}) {
+		for name, v := range cols {
+			switch name {
+			case "name":
+				p.Name = v.(string)
+			case "price":
+				p.Price = v.(float64)
+			}
+		}
+	},
+}
+
+// memRepo implements the operations users and products share.
+type memRepo[T any] struct {
+	m      *Memory
+	entity memEntity[T]
+}
+
+// find returns the index of the row with uuid, or -1. Soft-deleted rows
+// only count with unscoped.
+func (r memRepo[T]) find(s *memState, uuid string, unscoped bool) int {
+	uuid = strings.ToLower(uuid)
+	return slices.IndexFunc(r.entity.table(s).rows, func(row T) bool {
+		meta := r.entity.meta(&row)
+		return *meta.UUID == uuid && (unscoped || !meta.DeletedAt.Valid)
+	})
+}
+
+func (r memRepo[T]) all(ctx context.Context) ([]T, error) {
+	var rows []T
+	err := r.m.do(ctx, func(s *memState) error {
+		for _, row := range r.entity.table(s).rows {
+			if !r.entity.meta(&row).DeletedAt.Valid {
+				rows = append(rows, row)
+			}
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// matching returns the rows keep accepts, soft-deleted ones only with
+// unscoped, sorted as o asks and resuming after its cursor. total counts
+// the matches before the cursor is applied.
+func (r memRepo[T]) matching(ctx context.Context, o listOrder, unscoped bool, keep func(T) bool) (rows []T, total int, err error) {
+	err = r.m.do(ctx, func(s *memState) error {
+		for _, row := range r.entity.table(s).rows {
+			if (unscoped || !r.entity.meta(&row).DeletedAt.Valid) && keep(row) {
+				rows = append(rows, row)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	total = len(rows)
+
+	// compare orders by the sort column, then uuid, like the SQL
+	// "ORDER BY column, uuid" and "(column, uuid) > (?, ?)".
+	compare := func(v any, uuid string, row T) int {
+		c := compareValues(v, r.entity.column(row, o.column))
+		if c == 0 {
+			c = strings.Compare(uuid, *r.entity.meta(&row).UUID)
+		}
+		if o.desc {
+			c = -c
+		}
+		return c
+	}
+	slices.SortFunc(rows, func(a, b T) int {
+		return compare(r.entity.column(a, o.column), *r.entity.meta(&a).UUID, b)
+	})
+	if o.after != nil {
+		i := 0
+		for i < len(rows) && compare(o.after.Value, o.after.UUID, rows[i]) >= 0 {
+			i++
+		}
+		rows = rows[i:]
+	}
+	return rows, total, nil
+}
+
+// compareValues orders two values of the same sort kind.
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+func (r memRepo[T]) list(ctx context.Context, p ListParams, unscoped bool, keep func(T) bool) (Page[T], error) {
+	page := Page[T]{Items: []T{}}
+	o, err := resolveOrder(p, r.entity.sorts)
+	if err != nil {
+		return page, err
+	}
+	rows, total, err := r.matching(ctx, o, unscoped, keep)
+	if err != nil {
+		return page, err
+	}
+	if p.IncludeTotal {
+		n := int64(total)
+		page.Total = &n
+	}
+
+	limit := pageLimit(p)
+	if len(rows) > limit+1 {
+		rows = rows[:limit+1]
+	}
+	return fillPage(page, rows, limit, o)
+}
+
+// stream hands the matching rows to fn outside the lock, so fn may be slow
+// or call back into the store.
+func (r memRepo[T]) stream(ctx context.Context, p ListParams, unscoped bool, keep func(T) bool, fn func(T) error) error {
+	o, err := resolveOrder(p, r.entity.sorts)
+	if err != nil {
+		return err
+	}
+	rows, _, err := r.matching(ctx, o, unscoped, keep)
+	if err != nil {
+		return err
+	}
+	if p.Limit > 0 && len(rows) > p.Limit {
+		rows = rows[:p.Limit]
+	}
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r memRepo[T]) get(ctx context.Context, uuid string, unscoped bool) (T, error) {
+	var row T
+	err := r.m.do(ctx, func(s *memState) error {
+		i := r.find(s, uuid, unscoped)
+		if i < 0 {
+			return ErrNotFound
+		}
+		row = r.entity.table(s).rows[i]
+		return nil
+	})
+	return row, err
+}
+
+// create fills in what the database would: serial ID, UUID, version 1 and
+// created_at, kept at the microsecond precision Postgres stores.
+func (r memRepo[T]) create(ctx context.Context, row T) (T, error) {
+	err := r.m.do(ctx, func(s *memState) error {
+		meta := r.entity.meta(&row)
+		if *meta.UUID == "" {
+			*meta.UUID = uuidpkg.New()
+		}
+		*meta.UUID = strings.ToLower(*meta.UUID)
+		if r.find(s, *meta.UUID, true) >= 0 {
+			return ErrConflict
+		}
+
+		t := r.entity.table(s)
+		if *meta.ID == 0 {
+			*meta.ID = t.nextID + 1
+		}
+		t.nextID = max(t.nextID, *meta.ID)
+		if *meta.Version == 0 {
+			*meta.Version = 1
+		}
+		if meta.CreatedAt.IsZero() {
+			*meta.CreatedAt = time.Now()
+		}
+		*meta.CreatedAt = meta.CreatedAt.Truncate(time.Microsecond)
+
+		t.rows = append(t.rows, row)
+		return nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return row, nil
+}
+
+func (r memRepo[T]) upsert(ctx context.Context, row T) (T, bool, error) {
+	saved, err := r.create(ctx, row)
+	if !errors.Is(err, ErrConflict) {
+		return saved, err == nil, err
+	}
+	saved, err = r.update(ctx, *r.entity.meta(&row).UUID, r.entity.columns(row), 0)
+	if errors.Is(err, ErrNotFound) {
+		err = ErrConflict
+	}
+	return saved, false, err
+}
+
+// update writes cols to the live row with uuid and bumps its version. A
+// non-zero version must match the stored one.
+func (r memRepo[T]) update(ctx context.Context, uuid string, cols map[string]interface{}, version int) (T, error) {
+	var updated T
+	err := r.m.do(ctx, func(s *memState) error {
+		i := r.find(s, uuid, false)
+		if i < 0 {
+			return ErrNotFound
+		}
+		row := &r.entity.table(s).rows[i]
+		meta := r.entity.meta(row)
+		if version > 0 && *meta.Version != version {
+			return ErrVersionMismatch
+		}
+		r.entity.assign(row, cols)
+		*meta.Version++
+		updated = *row
+		return nil
+	})
+	return updated, err
+}
+
+func (r memRepo[T]) patch(ctx context.Context, uuid string, row T, fields []string, version int) (T, error) {
+	all := r.entity.columns(row)
+	cols := map[string]interface{}{}
+	for _, f := range fields {
+		if val, ok := all[f]; ok {
+			cols[f] = val
+		}
+	}
+	if len(cols) == 0 {
+		return r.get(ctx, uuid, false)
+	}
+	return r.update(ctx, uuid, cols, version)
+}
+
+func (r memRepo[T]) delete(ctx context.Context, uuid string, version int) error {
+	return r.m.do(ctx, func(s *memState) error {
+		i := r.find(s, uuid, false)
+		if i < 0 {
+			return ErrNotFound
+		}
+		meta := r.entity.meta(&r.entity.table(s).rows[i])
+		if version > 0 && *meta.Version != version {
+			return ErrVersionMismatch
+		}
+		*meta.DeletedAt = gorm.DeletedAt{Time: time.Now().Truncate(time.Microsecond), Valid: true}
+		return nil
+	})
+}
+
+// trashed returns the index of the soft-deleted row with uuid, or the
+// error explaining why there is none.
+func (r memRepo[T]) trashed(s *memState, uuid string) (int, error) {
+	i := r.find(s, uuid, true)
+	if i < 0 {
+		return i, ErrNotFound
+	}
+	if !r.entity.meta(&r.entity.table(s).rows[i]).DeletedAt.Valid {
+		return i, ErrNotInTrash
+	}
+	return i, nil
+}
+
+func (r memRepo[T]) restore(ctx context.Context, uuid string) (T, error) {
+	var restored T
+	err := r.m.do(ctx, func(s *memState) error {
+		i, err := r.trashed(s, uuid)
+		if err != nil {
+			return err
+		}
+		row := &r.entity.table(s).rows[i]
+		meta := r.entity.meta(row)
+		*meta.DeletedAt = gorm.DeletedAt{}
+		*meta.Version++
+		restored = *row
+		return nil
+	})
+	return restored, err
+}
+
+func (r memRepo[T]) purge(ctx context.Context, uuid string) error {
+	return r.m.do(ctx, func(s *memState) error {
+		i, err := r.trashed(s, uuid)
+		if err != nil {
+			return err
+		}
+		t := r.entity.table(s)
+		t.rows = slices.Delete(t.rows, i, i+1)
+		return nil
+	})
+}
+
+func (r memRepo[T]) purgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := r.m.do(ctx, func(s *memState) error {
+		t := r.entity.table(s)
+		t.rows = slices.DeleteFunc(t.rows, func(row T) bool {
+			deleted := r.entity.meta(&row).DeletedAt
+			if deleted.Valid && deleted.Time.Before(cutoff) {
+				purged++
+				return true
+			}
+			return false
+		})
+		return nil
+	})
+	return purged, err
+}
+
+// memUsers is the in-memory UserRepository.
+type memUsers struct{ memRepo[models.User] }
+
+func (r memUsers) All(ctx context.Context) ([]models.User, error) { return r.all(ctx) }
+
+func (r memUsers) List(ctx context.Context, q UserQuery) (Page[models.User], error) {
+	return r.list(ctx, q.ListParams, q.IncludeDeleted, q.matches)
+}
+
+func (r memUsers) Stream(ctx context.Context, q UserQuery, fn func(models.User) error) error {
+	return r.stream(ctx, q.ListParams, q.IncludeDeleted, q.matches, fn)
+}
+
+// matches is userListQuery's filter applied to one user.
+func (q UserQuery) matches(u models.User) bool {
+	return (q.Role == "" || u.Role == q.Role) &&
+		(q.NameContains == "" || strings.Contains(strings.ToLower(u.Name), strings.ToLower(q.NameContains))) &&
+		(q.CreatedAfter.IsZero() || u.CreatedAt.After(q.CreatedAfter))
+}
+
+func (r memUsers) Get(ctx context.Context, uuid string) (models.User, error) {
+	return r.get(ctx, uuid, false)
+}
+
+func (r memUsers) GetIncludingDeleted(ctx context.Context, uuid string) (models.User, error) {
+	return r.get(ctx, uuid, true)
+}
+
+func (r memUsers) Create(ctx context.Context, u models.User) (models.User, error) {
+	return r.create(ctx, u)
+}
+
+func (r memUsers) Upsert(ctx context.Context, u models.User) (models.User, bool, error) {
+	return r.upsert(ctx, u)
+}
+
+func (r memUsers) Update(ctx context.Context, uuid string, u models.User, version int) (models.User, error) {
+	return r.update(ctx, uuid, userColumns(u), version)
+}
+
+func (r memUsers) Patch(ctx context.Context, uuid string, u models.User, fields []string, version int) (models.User, error) {
+	return r.patch(ctx, uuid, u, fields, version)
+}
+
+func (r memUsers) Delete(ctx context.Context, uuid string, version int) error {
+	return r.delete(ctx, uuid, version)
+}
+
+func (r memUsers) Restore(ctx context.Context, uuid string) (models.User, error) {
+	return r.restore(ctx, uuid)
+}
+
+func (r memUsers) Purge(ctx context.Context, uuid string) error { return r.purge(ctx, uuid) }
+
+func (r memUsers) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.purgeDeletedBefore(ctx, cutoff)
+}
+
+// memProducts is the in-memory ProductRepository.
+type memProducts struct{ memRepo[models.Product] }
+
+func (r memProducts) All(ctx context.Context) ([]models.Product, error) { return r.all(ctx) }
+
+func (r memProducts) List(ctx context.Context, q ProductQuery) (Page[models.Product], error) {
+	return r.list(ctx, q.ListParams, q.IncludeDeleted, q.matches)
+}
+
+func (r memProducts) Stream(ctx context.Context, q ProductQuery, fn func(models.Product) error) error {
+	return r.stream(ctx, q.ListParams, q.IncludeDeleted, q.matches, fn)
+}
+
+// matches is productListQuery's filter applied to one product.
+func (q ProductQuery) matches(p models.Product) bool {
+	return (q.PriceGTE == nil || p.Price >= *q.PriceGTE) &&
+		(q.NameContains == "" || strings.Contains(strings.ToLower(p.Name), strings.ToLower(q.NameContains))) &&
+		(q.CreatedAfter.IsZero() || p.CreatedAt.After(q.CreatedAfter))
+}
+
+func (r memProducts) Get(ctx context.Context, uuid string) (models.Product, error) {
+	return r.get(ctx, uuid, false)
+}
+
+func (r memProducts) GetIncludingDeleted(ctx context.Context, uuid string) (models.Product, error) {
+	return r.get(ctx, uuid, true)
+}
+
+func (r memProducts) Create(ctx context.Context, p models.Product) (models.Product, error) {
+	return r.create(ctx, p)
+}
+
+func (r memProducts) Upsert(ctx context.Context, p models.Product) (models.Product, bool, error) {
+	return r.upsert(ctx, p)
+}
+
+func (r memProducts) Update(ctx context.Context, uuid string, p models.Product, version int) (models.Product, error) {
+	return r.update(ctx, uuid, productColumns(p), version)
+}
+
+func (r memProducts) Patch(ctx context.Context, uuid string, p models.Product, fields []string, version int) (models.Product, error) {
+	return r.patch(ctx, uuid, p, fields, version)
+}
+
+func (r memProducts) Delete(ctx context.Context, uuid string, version int) error {
+	return r.delete(ctx, uuid, version)
+}
+
+func (r memProducts) Restore(ctx context.Context, uuid string) (models.Product, error) {
+	return r.restore(ctx, uuid)
+}
+
+func (r memProducts) Purge(ctx context.Context, uuid string) error { return r.purge(ctx, uuid) }
+
+func (r memProducts) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.purgeDeletedBefore(ctx, cutoff)
+}
+
+func (r memProducts) Import(ctx context.Context, rows []ImportRow, dryRun bool) ([]ImportResult, error) {
+	return importRows(ctx, r.m, rows, dryRun, r.importProduct)
+}
+
+// importProduct matches p like the GORM importProduct does.
+func (r memProducts) importProduct(ctx context.Context, p models.Product) (models.Product, bool, error) {
+	var matches []models.Product
+	err := r.m.do(ctx, func(s *memState) error {
+		for _, row := range s.products.rows {
+			if p.UUID != "" && row.UUID == strings.ToLower(p.UUID) ||
+				p.UUID == "" && row.Name == p.Name && !row.DeletedAt.Valid {
+				matches = append(matches, row)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Product{}, false, err
+	}
+
+	switch {
+	case len(matches) == 0:
+		saved, err := r.create(ctx, p)
+		return saved, err == nil, err
+	case len(matches) > 1:
+		return models.Product{}, false, errAmbiguousName
+	case matches[0].DeletedAt.Valid:
+		return models.Product{}, false, errImportDeleted
+	}
+	updated, err := r.update(ctx, matches[0].UUID, productColumns(p), 0)
+	return updated, false, err
+}
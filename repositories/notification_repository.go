@@ -1,27 +1,27 @@
 package repositories
 
 import (
+	"context"
+	"time"
+
 	"go-demo/database"
 	"go-demo/models"
-	"go-demo/pkg/logger"
-	"time"
+
+	"gorm.io/gorm"
 )
 
-// CreateNotificationOutbox inserts a new job into the notification outbox.
-func CreateNotificationOutbox(eventType, payload string) {
-	if database.GormDB == nil {
-		logger.Log.Warn().Msg("notification outbox insert skipped: no DB connection")
-		return
-	}
+type gormOutbox struct{ db *gorm.DB }
 
-	outboxMsg := models.NotificationOutbox{
-		EventType: eventType,
-		Payload:   payload,
-		Status:    "PENDING",
-		CreatedAt: time.Now(),
-	}
+func (o gormOutbox) Enqueue(ctx context.Context, eventType, entityUUID, payload string) error {
+	// outlive the request: a client hanging up must not drop the message
+	db := database.Conn(context.WithoutCancel(ctx), o.db)
 
-	if err := database.GormDB.Create(&outboxMsg).Error; err != nil {
-		logger.Log.Error().Err(err).Msg("failed to insert into notification outbox")
+	outboxMsg := models.NotificationOutbox{
+		EventType:  eventType,
+		Payload:    payload,
+		EntityUUID: entityUUID,
+		Status:     "PENDING",
+		CreatedAt:  time.Now(),
 	}
+	return mapError(db.Create(&outboxMsg).Error)
 }
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-demo/database"
+	"go-demo/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outcomes of one imported row.
+const (
+	ImportCreated  = "created"
+	ImportUpdated  = "updated"
+	ImportRejected = "rejected"
+)
+
+// ImportRow is one product read from an import file. Line is its position
+// in the file, used only for reporting.
+type ImportRow struct {
+	Line    int
+	Product models.Product
+}
+
+// ImportResult reports what happened to one row.
+type ImportResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	UUID   string `json:"uuid,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// errDryRun rolls back a dry-run batch once every row has been tried.
+var errDryRun = errors.New("dry run")
+
+// Import upserts rows in one transaction. A row with a UUID updates
+// the product holding it, or is created under it; a row without one
+// updates the live product of the same name, or is created. Each row runs
+// in its own savepoint, so a rejected row does not abort the others. With
+// dryRun every row is tried and the transaction rolled back, so the
+// results show what an import would do.
+func (r gormProducts) Import(ctx context.Context, rows []ImportRow, dryRun bool) ([]ImportResult, error) {
+	return importRows(ctx, gormUnitOfWork{r.db}, rows, dryRun, func(ctx context.Context, p models.Product) (models.Product, bool, error) {
+		return importProduct(database.Conn(ctx, r.db), p)
+	})
+}
+
+// importRows runs the import of rows as one unit of work on uow, with a
+// nested unit of work per row, calling importOne to store each product.
+// The returned error is only set when the batch as a whole failed; row
+// failures are reported in the results.
+func importRows(ctx context.Context, uow UnitOfWork, rows []ImportRow, dryRun bool,
+	importOne func(ctx context.Context, p models.Product) (saved models.Product, created bool, err error)) ([]ImportResult, error) {
+	results := make([]ImportResult, 0, len(rows))
+
+	err := uow.Transaction(ctx, func(ctx context.Context) error {
+		for _, row := range rows {
+			res := ImportResult{Line: row.Line}
+			err := uow.Transaction(ctx, func(ctx context.Context) error {
+				saved, created, err := importOne(ctx, row.Product)
+				res.UUID = saved.UUID
+				res.Status = ImportUpdated
+				if created {
+					res.Status = ImportCreated
+				}
+				return err
+			})
+			if err != nil {
+				res.Status, res.Reason = ImportRejected, importReason(err)
+			}
+			results = append(results, res)
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, mapError(err)
+	}
+	return results, nil
+}
+
+// Row failures specific to imports.
+var (
+	errAmbiguousName = errors.New("name matches more than one product")
+	errImportDeleted = fmt.Errorf("%w: uuid belongs to a deleted product", ErrConflict)
+)
+
+func importProduct(tx *gorm.DB, p models.Product) (models.Product, bool, error) {
+	var matches []models.Product
+	q := tx.Unscoped().Limit(2)
+	if p.UUID != "" {
+		q = q.Where("uuid = ?", p.UUID)
+	} else {
+		q = q.Where("name = ? AND deleted_at IS NULL", p.Name)
+	}
+	if err := q.Find(&matches).Error; err != nil {
+		return models.Product{}, false, mapError(err)
+	}
+
+	switch {
+	case len(matches) == 0:
+		if err := tx.Create(&p).Error; err != nil {
+			return models.Product{}, false, mapError(err)
+		}
+		return p, true, nil
+	case len(matches) > 1:
+		return models.Product{}, false, errAmbiguousName
+	case matches[0].DeletedAt.Valid:
+		return models.Product{}, false, errImportDeleted
+	}
+
+	var updated models.Product
+	cols := productColumns(p)
+	cols["version"] = gorm.Expr("version + 1")
+	res := tx.Model(&updated).Clauses(clause.Returning{}).Where("uuid = ?", matches[0].UUID).Updates(cols)
+	if err := affected(res); err != nil {
+		return models.Product{}, false, err
+	}
+	return updated, false, nil
+}
+
+// importReason describes a row failure without leaking driver messages.
+func importReason(err error) string {
+	switch {
+	case errors.Is(err, errAmbiguousName):
+		return "name matches more than one product; give its uuid"
+	case errors.Is(err, errImportDeleted):
+		return "uuid belongs to a deleted product; restore it first"
+	case errors.Is(err, ErrConflict):
+		return "conflicts with an existing or deleted product"
+	case errors.Is(err, ErrConstraint):
+		return "violates a database constraint"
+	default:
+		return "could not be stored"
+	}
+}
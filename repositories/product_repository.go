@@ -1,26 +1,168 @@
 package repositories
 
 import (
+	"context"
+	"errors"
 	"go-demo/database"
 	"go-demo/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func GetProducts() ([]models.Product, error) {
+// gormProducts is the ProductRepository backed by GORM.
+type gormProducts struct{ db *gorm.DB }
+
+func (r gormProducts) All(ctx context.Context) ([]models.Product, error) {
 	var products []models.Product
-	if err := database.GormDB.Find(&products).Error; err != nil {
-		return nil, err
+	if err := database.Conn(ctx, r.db).Find(&products).Error; err != nil {
+		return nil, mapError(err)
 	}
 	return products, nil
 }
 
-func CreateProduct(p models.Product) error {
-	return database.GormDB.Create(&p).Error
+// ProductQuery filters and pages the product list. Zero-valued filters are
+// ignored.
+type ProductQuery struct {
+	ListParams
+	PriceGTE     *float64
+	NameContains string
+	CreatedAfter time.Time
+	// IncludeDeleted also lists soft-deleted products.
+	IncludeDeleted bool
+}
+
+var productSorts = map[string]sortKind{
+	"name":       sortString,
+	"price":      sortNumber,
+	"created_at": sortTime,
+}
+
+// ProductSortKeys lists the values ?sort= accepts on the product list.
+func ProductSortKeys() []string {
+	return sortKeys(productSorts)
+}
+
+func (r gormProducts) List(ctx context.Context, q ProductQuery) (Page[models.Product], error) {
+	return paginate[models.Product](r.listQuery(ctx, q), q.ListParams, productSorts)
+}
+
+// Stream reads rows from a database cursor instead of loading a page.
+func (r gormProducts) Stream(ctx context.Context, q ProductQuery, fn func(models.Product) error) error {
+	return stream(r.listQuery(ctx, q), q.ListParams, productSorts, fn)
+}
+
+// listQuery applies q's filters.
+func (r gormProducts) listQuery(ctx context.Context, q ProductQuery) *gorm.DB {
+	db := database.Conn(ctx, r.db).Model(&models.Product{})
+	if q.IncludeDeleted {
+		db = db.Unscoped()
+	}
+	if q.PriceGTE != nil {
+		db = db.Where("price >= ?", *q.PriceGTE)
+	}
+	if q.NameContains != "" {
+		db = db.Where(`LOWER(name) LIKE ? ESCAPE '\'`, containsPattern(q.NameContains))
+	}
+	if !q.CreatedAfter.IsZero() {
+		db = db.Where("created_at > ?", q.CreatedAfter)
+	}
+	return db
+}
+
+func (r gormProducts) Get(ctx context.Context, uuid string) (models.Product, error) {
+	var product models.Product
+	if err := database.Conn(ctx, r.db).Where("uuid = ?", uuid).First(&product).Error; err != nil {
+		return models.Product{}, mapError(err)
+	}
+	return product, nil
+}
+
+func (r gormProducts) GetIncludingDeleted(ctx context.Context, uuid string) (models.Product, error) {
+	return getIncludingDeleted[models.Product](database.Conn(ctx, r.db), uuid)
+}
+
+func (r gormProducts) Create(ctx context.Context, p models.Product) (models.Product, error) {
+	if err := database.Conn(ctx, r.db).Create(&p).Error; err != nil {
+		return models.Product{}, mapError(err)
+	}
+	return p, nil
+}
+
+func (r gormProducts) Upsert(ctx context.Context, p models.Product) (result models.Product, created bool, err error) {
+	// The insert runs in its own savepoint: inside a transaction a failed
+	// statement would otherwise abort everything after it.
+	err = database.Transaction(ctx, r.db, func(ctx context.Context) error {
+		result, err = r.Create(ctx, p)
+		return err
+	})
+	if !errors.Is(err, ErrConflict) {
+		return result, err == nil, err
+	}
+
+	result, err = r.update(ctx, p.UUID, productColumns(p), 0)
+	if errors.Is(err, ErrNotFound) {
+		err = ErrConflict
+	}
+	return result, false, err
+}
+
+// productColumns maps the mutable columns of p to their values.
+func productColumns(p models.Product) map[string]interface{} {
+	return map[string]interface{}{"name": p.Name, "price": p.Price}
+}
+
+func (r gormProducts) Update(ctx context.Context, uuid string, p models.Product, version int) (models.Product, error) {
+	return r.update(ctx, uuid, productColumns(p), version)
+}
+
+func (r gormProducts) Patch(ctx context.Context, uuid string, p models.Product, fields []string, version int) (models.Product, error) {
+	all := productColumns(p)
+	cols := map[string]interface{}{}
+	for _, f := range fields {
+		if val, ok := all[f]; ok {
+			cols[f] = val
+		}
+	}
+	if len(cols) == 0 {
+		return r.Get(ctx, uuid)
+	}
+	return r.update(ctx, uuid, cols, version)
+}
+
+func (r gormProducts) update(ctx context.Context, uuid string, cols map[string]interface{}, version int) (models.Product, error) {
+	db := database.Conn(ctx, r.db)
+	var updated models.Product
+	q := db.Model(&updated).Clauses(clause.Returning{}).Where("uuid = ?", uuid)
+	if version > 0 {
+		q = q.Where("version = ?", version)
+	}
+	cols["version"] = gorm.Expr("version + 1")
+	res := q.Updates(cols)
+	if err := affectedVersioned(db, res, &models.Product{}, uuid, version); err != nil {
+		return models.Product{}, err
+	}
+	return updated, nil
+}
+
+func (r gormProducts) Delete(ctx context.Context, uuid string, version int) error {
+	db := database.Conn(ctx, r.db)
+	q := db.Where("uuid = ?", uuid)
+	if version > 0 {
+		q = q.Where("version = ?", version)
+	}
+	return affectedVersioned(db, q.Delete(&models.Product{}), &models.Product{}, uuid, version)
+}
+
+func (r gormProducts) Restore(ctx context.Context, uuid string) (models.Product, error) {
+	return restore[models.Product](database.Conn(ctx, r.db), uuid)
 }
 
-func UpdateProduct(id int, p models.Product) error {
-	return database.GormDB.Model(&models.Product{}).Where("id = ?", id).Updates(map[string]interface{}{"name": p.Name, "price": p.Price}).Error
+func (r gormProducts) Purge(ctx context.Context, uuid string) error {
+	return purge[models.Product](database.Conn(ctx, r.db), uuid)
 }
 
-func DeleteProduct(id int) error {
-	return database.GormDB.Delete(&models.Product{}, id).Error
+func (r gormProducts) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return purgeDeletedBefore[models.Product](database.Conn(ctx, r.db), cutoff)
 }
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go-demo/database"
+	"go-demo/models"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork groups repository calls into one transaction. Every call made
+// with the ctx handed to fn commits when fn returns nil and is rolled back
+// when it returns an error. Nested units of work become savepoints of the
+// outer one.
+type UnitOfWork interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// UserRepository stores users. Methods return the errors declared in this
+// package, matched with errors.Is.
+type UserRepository interface {
+	All(ctx context.Context) ([]models.User, error)
+	List(ctx context.Context, q UserQuery) (Page[models.User], error)
+	// Stream calls fn for every user matching q, in q's sort order.
+	// q.Limit and q.Cursor apply when set.
+	Stream(ctx context.Context, q UserQuery, fn func(models.User) error) error
+	Get(ctx context.Context, uuid string) (models.User, error)
+	// GetIncludingDeleted is Get that also finds soft-deleted users.
+	GetIncludingDeleted(ctx context.Context, uuid string) (models.User, error)
+	// Create inserts u and returns it with the generated ID, UUID and
+	// CreatedAt filled in.
+	Create(ctx context.Context, u models.User) (models.User, error)
+	// Upsert creates u under its client-supplied UUID or, when a user with
+	// that UUID already exists, overwrites its mutable columns. created
+	// reports which of the two happened. A UUID held by a soft-deleted user
+	// is a conflict.
+	Upsert(ctx context.Context, u models.User) (saved models.User, created bool, err error)
+	// Update overwrites the mutable columns of the user with uuid, bumps
+	// its version and returns the row as stored. A non-zero version makes
+	// the write conditional on the stored version matching.
+	Update(ctx context.Context, uuid string, u models.User, version int) (models.User, error)
+	// Patch writes only the named columns of u, so concurrent edits of
+	// other columns are not overwritten. Unknown and read-only names are
+	// ignored; if nothing is left to write the stored user is returned as is.
+	Patch(ctx context.Context, uuid string, u models.User, fields []string, version int) (models.User, error)
+	// Delete soft-deletes the user with uuid. A non-zero version makes the
+	// delete conditional on the stored version matching.
+	Delete(ctx context.Context, uuid string, version int) error
+	// Restore brings a soft-deleted user back and returns it.
+	Restore(ctx context.Context, uuid string) (models.User, error)
+	// Purge permanently removes a soft-deleted user.
+	Purge(ctx context.Context, uuid string) error
+	// PurgeDeletedBefore permanently removes users soft-deleted before
+	// cutoff and returns how many were removed.
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// ProductRepository stores products. It mirrors UserRepository and adds
+// bulk imports.
+type ProductRepository interface {
+	All(ctx context.Context) ([]models.Product, error)
+	List(ctx context.Context, q ProductQuery) (Page[models.Product], error)
+	Stream(ctx context.Context, q ProductQuery, fn func(models.Product) error) error
+	Get(ctx context.Context, uuid string) (models.Product, error)
+	GetIncludingDeleted(ctx context.Context, uuid string) (models.Product, error)
+	Create(ctx context.Context, p models.Product) (models.Product, error)
+	Upsert(ctx context.Context, p models.Product) (saved models.Product, created bool, err error)
+	Update(ctx context.Context, uuid string, p models.Product, version int) (models.Product, error)
+	Patch(ctx context.Context, uuid string, p models.Product, fields []string, version int) (models.Product, error)
+	Delete(ctx context.Context, uuid string, version int) error
+	Restore(ctx context.Context, uuid string) (models.Product, error)
+	Purge(ctx context.Context, uuid string) error
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	// Import upserts rows as one unit of work. A row with a UUID updates
+	// the product holding it, or is created under it; a row without one
+	// updates the live product of the same name, or is created. With dryRun
+	// the unit of work is rolled back. The error is only set when the batch
+	// as a whole failed; row failures are reported in the results.
+	Import(ctx context.Context, rows []ImportRow, dryRun bool) ([]ImportResult, error)
+}
+
+// AuditPublisher queues audit events for the audit worker.
+type AuditPublisher interface {
+	// Publish queues ev inside the unit of work bound to ctx, if any, so
+	// the event commits or rolls back with the change it records. A client
+	// hanging up does not cancel the write.
+	Publish(ctx context.Context, ev models.AuditLog) error
+}
+
+// NotificationOutbox queues notification jobs for the notification worker.
+type NotificationOutbox interface {
+	// Enqueue adds a PENDING job inside the unit of work bound to ctx, if
+	// any, so a job is only ever sent for changes that committed.
+	// entityUUID identifies the entity the notification is about.
+	Enqueue(ctx context.Context, eventType, entityUUID, payload string) error
+}
+
+// IdempotencyStore keeps the Idempotency-Key records of POST requests.
+type IdempotencyStore interface {
+	// Claim records key as in progress. It returns claimed=true when the
+	// caller owns the key and must run the request; otherwise the stored
+	// record is returned. Expired records are replaced.
+	Claim(ctx context.Context, key, fingerprint string, ttl time.Duration) (claimed bool, existing models.IdempotencyKey, err error)
+	// Complete stores the response produced for key.
+	Complete(ctx context.Context, key string, status int, headers string, body []byte) error
+	// Release forgets key so the request can be retried, e.g. after a
+	// server error.
+	Release(ctx context.Context, key string) error
+	// DeleteExpired removes keys that expired before now and returns how
+	// many were deleted.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// Store is one backend's set of repositories, sharing a unit of work.
+type Store struct {
+	Tx          UnitOfWork
+	Users       UserRepository
+	Products    ProductRepository
+	Audit       AuditPublisher
+	Outbox      NotificationOutbox
+	Idempotency IdempotencyStore
+}
+
+// NewGormStore returns the repositories backed by db.
+func NewGormStore(db *gorm.DB) Store {
+	return Store{
+		Tx:          gormUnitOfWork{db},
+		Users:       gormUsers{db},
+		Products:    gormProducts{db},
+		Audit:       gormAudit{db},
+		Outbox:      gormOutbox{db},
+		Idempotency: gormIdempotency{db},
+	}
+}
+
+type gormUnitOfWork struct{ db *gorm.DB }
+
+func (u gormUnitOfWork) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return database.Transaction(ctx, u.db, fn)
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotInTrash means the record exists but has not been soft-deleted, so
+// it cannot be restored or purged.
+var ErrNotInTrash = errors.New("record is not deleted")
+
+// getIncludingDeleted loads a row by uuid whether or not it is soft-deleted.
+func getIncludingDeleted[T any](db *gorm.DB, uuid string) (T, error) {
+	var row T
+	if err := db.Unscoped().Where("uuid = ?", uuid).First(&row).Error; err != nil {
+		return row, mapError(err)
+	}
+	return row, nil
+}
+
+// restore clears deleted_at on a soft-deleted row, bumps its version and
+// returns it.
+func restore[T any](db *gorm.DB, uuid string) (T, error) {
+	var restored T
+	res := db.Unscoped().Model(&restored).Clauses(clause.Returning{}).
+		Where("uuid = ? AND deleted_at IS NOT NULL", uuid).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if res.Error != nil {
+		return restored, mapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return restored, trashMiss[T](db, uuid)
+	}
+	return restored, nil
+}
+
+// purge permanently removes a soft-deleted row.
+func purge[T any](db *gorm.DB, uuid string) error {
+	res := db.Unscoped().Where("uuid = ? AND deleted_at IS NOT NULL", uuid).Delete(new(T))
+	if res.Error != nil {
+		return mapError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return trashMiss[T](db, uuid)
+	}
+	return nil
+}
+
+// purgeDeletedBefore permanently removes rows soft-deleted before cutoff.
+func purgeDeletedBefore[T any](db *gorm.DB, cutoff time.Time) (int64, error) {
+	res := db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(new(T))
+	return res.RowsAffected, mapError(res.Error)
+}
+
+// trashMiss explains why a trash operation touched no row: either uuid does
+// not exist at all or it is not soft-deleted.
+func trashMiss[T any](db *gorm.DB, uuid string) error {
+	var count int64
+	if err := db.Unscoped().Model(new(T)).Where("uuid = ?", uuid).Count(&count).Error; err != nil {
+		return mapError(err)
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrNotInTrash
+}
@@ -1,26 +1,167 @@
 package repositories
 
 import (
+	"context"
+	"errors"
 	"go-demo/database"
 	"go-demo/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func GetUsers() ([]models.User, error) {
+// gormUsers is the UserRepository backed by GORM.
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) All(ctx context.Context) ([]models.User, error) {
 	var users []models.User
-	if err := database.GormDB.Find(&users).Error; err != nil {
-		return nil, err
+	if err := database.Conn(ctx, r.db).Find(&users).Error; err != nil {
+		return nil, mapError(err)
 	}
 	return users, nil
 }
 
-func CreateUser(u models.User) error {
-	return database.GormDB.Create(&u).Error
+// UserQuery filters and pages the user list. Zero-valued filters are ignored.
+type UserQuery struct {
+	ListParams
+	Role         string
+	NameContains string
+	CreatedAfter time.Time
+	// IncludeDeleted also lists soft-deleted users.
+	IncludeDeleted bool
+}
+
+var userSorts = map[string]sortKind{
+	"name":       sortString,
+	"role":       sortString,
+	"created_at": sortTime,
+}
+
+// UserSortKeys lists the values ?sort= accepts on the user list.
+func UserSortKeys() []string {
+	return sortKeys(userSorts)
+}
+
+func (r gormUsers) List(ctx context.Context, q UserQuery) (Page[models.User], error) {
+	return paginate[models.User](r.listQuery(ctx, q), q.ListParams, userSorts)
+}
+
+// Stream reads rows from a database cursor instead of loading a page.
+func (r gormUsers) Stream(ctx context.Context, q UserQuery, fn func(models.User) error) error {
+	return stream(r.listQuery(ctx, q), q.ListParams, userSorts, fn)
+}
+
+// listQuery applies q's filters.
+func (r gormUsers) listQuery(ctx context.Context, q UserQuery) *gorm.DB {
+	db := database.Conn(ctx, r.db).Model(&models.User{})
+	if q.IncludeDeleted {
+		db = db.Unscoped()
+	}
+	if q.Role != "" {
+		db = db.Where("role = ?", q.Role)
+	}
+	if q.NameContains != "" {
+		db = db.Where(`LOWER(name) LIKE ? ESCAPE '\'`, containsPattern(q.NameContains))
+	}
+	if !q.CreatedAfter.IsZero() {
+		db = db.Where("created_at > ?", q.CreatedAfter)
+	}
+	return db
+}
+
+func (r gormUsers) Get(ctx context.Context, uuid string) (models.User, error) {
+	var user models.User
+	if err := database.Conn(ctx, r.db).Where("uuid = ?", uuid).First(&user).Error; err != nil {
+		return models.User{}, mapError(err)
+	}
+	return user, nil
+}
+
+func (r gormUsers) GetIncludingDeleted(ctx context.Context, uuid string) (models.User, error) {
+	return getIncludingDeleted[models.User](database.Conn(ctx, r.db), uuid)
+}
+
+func (r gormUsers) Create(ctx context.Context, u models.User) (models.User, error) {
+	if err := database.Conn(ctx, r.db).Create(&u).Error; err != nil {
+		return models.User{}, mapError(err)
+	}
+	return u, nil
+}
+
+func (r gormUsers) Upsert(ctx context.Context, u models.User) (result models.User, created bool, err error) {
+	// The insert runs in its own savepoint: inside a transaction a failed
+	// statement would otherwise abort everything after it.
+	err = database.Transaction(ctx, r.db, func(ctx context.Context) error {
+		result, err = r.Create(ctx, u)
+		return err
+	})
+	if !errors.Is(err, ErrConflict) {
+		return result, err == nil, err
+	}
+
+	result, err = r.update(ctx, u.UUID, userColumns(u), 0)
+	if errors.Is(err, ErrNotFound) {
+		err = ErrConflict
+	}
+	return result, false, err
+}
+
+// userColumns maps the mutable columns of u to their values.
+func userColumns(u models.User) map[string]interface{} {
+	return map[string]interface{}{"name": u.Name, "role": u.Role}
+}
+
+func (r gormUsers) Update(ctx context.Context, uuid string, u models.User, version int) (models.User, error) {
+	return r.update(ctx, uuid, userColumns(u), version)
+}
+
+func (r gormUsers) Patch(ctx context.Context, uuid string, u models.User, fields []string, version int) (models.User, error) {
+	all := userColumns(u)
+	cols := map[string]interface{}{}
+	for _, f := range fields {
+		if val, ok := all[f]; ok {
+			cols[f] = val
+		}
+	}
+	if len(cols) == 0 {
+		return r.Get(ctx, uuid)
+	}
+	return r.update(ctx, uuid, cols, version)
+}
+
+func (r gormUsers) update(ctx context.Context, uuid string, cols map[string]interface{}, version int) (models.User, error) {
+	db := database.Conn(ctx, r.db)
+	var updated models.User
+	q := db.Model(&updated).Clauses(clause.Returning{}).Where("uuid = ?", uuid)
+	if version > 0 {
+		q = q.Where("version = ?", version)
+	}
+	cols["version"] = gorm.Expr("version + 1")
+	res := q.Updates(cols)
+	if err := affectedVersioned(db, res, &models.User{}, uuid, version); err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+func (r gormUsers) Delete(ctx context.Context, uuid string, version int) error {
+	db := database.Conn(ctx, r.db)
+	q := db.Where("uuid = ?", uuid)
+	if version > 0 {
+		q = q.Where("version = ?", version)
+	}
+	return affectedVersioned(db, q.Delete(&models.User{}), &models.User{}, uuid, version)
+}
+
+func (r gormUsers) Restore(ctx context.Context, uuid string) (models.User, error) {
+	return restore[models.User](database.Conn(ctx, r.db), uuid)
 }
 
-func UpdateUser(id int, u models.User) error {
-	return database.GormDB.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{"name": u.Name, "role": u.Role}).Error
+func (r gormUsers) Purge(ctx context.Context, uuid string) error {
+	return purge[models.User](database.Conn(ctx, r.db), uuid)
 }
 
-func DeleteUser(id int) error {
-	return database.GormDB.Delete(&models.User{}, id).Error
+func (r gormUsers) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return purgeDeletedBefore[models.User](database.Conn(ctx, r.db), cutoff)
 }
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"go-demo/models"
+	"go-demo/pkg/apiversion"
+	uuidpkg "go-demo/pkg/uuid"
+	"go-demo/repositories"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type batchResponseBody struct {
+	Committed bool `json:"committed"`
+	Results   []struct {
+		Index   int               `json:"index"`
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers"`
+		Body    json.RawMessage   `json:"body"`
+	} `json:"results"`
+}
+
+var _ = Describe("Batch", func() {
+	var mux *http.ServeMux
+
+	BeforeEach(func() {
+		mux = newTestMux()
+	})
+
+	post := func(body string) (*httptest.ResponseRecorder, batchResponseBody) {
+		req := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		var resp batchResponseBody
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr, resp
+	}
+
+	It("commits every operation together", func() {
+		userID := uuidpkg.New()
+		rr, resp := post(`{"operations":[
+			{"method":"POST","path":"/users","body":{"uuid":"` + userID + `","name":"Batch User","role":"Buyer"}},
+			{"method":"POST","path":"/products","body":{"name":"Batch Product","price":4}},
+			{"method":"PATCH","path":"/users/` + userID + `","headers":{"If-Match":"\"1\""},"body":{"role":"Seller"}}
+		]}`)
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(resp.Committed).To(BeTrue())
+		Expect(resp.Results).To(HaveLen(3))
+		Expect(resp.Results[0].Status).To(Equal(http.StatusCreated))
+		Expect(resp.Results[0].Headers["Location"]).To(Equal("/users/" + userID))
+		Expect(resp.Results[2].Status).To(Equal(http.StatusOK))
+
+		user, err := testStore.Users.Get(context.Background(), userID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(user.Role).To(Equal("Seller"))
+	})
+
+	It("rolls back entities, audit and outbox rows when one operation fails", func() {
+		userID := uuidpkg.New()
+		rr, resp := post(`{"operations":[
+			{"method":"POST","path":"/users","body":{"uuid":"` + userID + `","name":"Doomed","role":"Buyer"}},
+			{"method":"PUT","path":"/products/00000000-0000-4000-8000-000000000000","body":{"name":"Ghost","price":1}},
+			{"method":"POST","path":"/products","body":{"name":"Never","price":1}}
+		]}`)
+
+		Expect(rr.Code).To(Equal(http.StatusUnprocessableEntity))
+		Expect(resp.Committed).To(BeFalse())
+		Expect(resp.Results).To(HaveLen(2))
+		Expect(resp.Results[1].Status).To(Equal(http.StatusNotFound))
+
+		_, err := testStore.Users.GetIncludingDeleted(context.Background(), userID)
+		Expect(errors.Is(err, repositories.ErrNotFound)).To(BeTrue())
+
+		Expect(auditEvents(userID)).To(BeEmpty())
+		Expect(outboxJobs(userID)).To(BeEmpty())
+	})
+
+	It("upserts inside a batch without aborting the transaction", func() {
+		existing, err := testStore.Products.Create(context.Background(), models.Product{Name: "Batch Upsert", Price: 1})
+		Expect(err).NotTo(HaveOccurred())
+
+		rr, resp := post(`{"operations":[
+			{"method":"POST","path":"/products","body":{"uuid":"` + existing.UUID + `","name":"Batch Upsert","price":2}},
+			{"method":"GET","path":"/products/` + existing.UUID + `"}
+		]}`)
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(resp.Results[0].Status).To(Equal(http.StatusOK))
+		Expect(string(resp.Results[1].Body)).To(ContainSubstring(`"price":2`))
+	})
+
+	It("links results into the version the batch was sent to", func() {
+		versioned := http.NewServeMux()
+		apiversion.NewRouter(versioned).Mount(apiversion.Version{Name: "v1", Handler: mux})
+		userID := uuidpkg.New()
+		req := httptest.NewRequest(http.MethodPost, "/v1/batch", bytes.NewBufferString(`{"operations":[
+			{"method":"POST","path":"/users","body":{"uuid":"`+userID+`","name":"Versioned Batch User","role":"Buyer"}}
+		]}`))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		versioned.ServeHTTP(rr, req)
+
+		var resp batchResponseBody
+		Expect(json.Unmarshal(rr.Body.Bytes(), &resp)).To(Succeed())
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(resp.Results[0].Headers["Location"]).To(Equal("/v1/users/" + userID))
+	})
+
+	It("rejects operations outside the user and product resources", func() {
+		for _, body := range []string{
+			`{"operations":[{"method":"POST","path":"/batch","body":{"operations":[]}}]}`,
+			`{"operations":[{"method":"POST","path":"/products/import"}]}`,
+			`{"operations":[{"method":"GET","path":"/users","headers":{"Authorization":"x"}}]}`,
+			`{"operations":[]}`,
+		} {
+			rr, _ := post(body)
+			Expect(rr.Code).To(BeNumerically(">=", http.StatusBadRequest), body)
+			Expect(rr.Code).To(BeNumerically("<", http.StatusInternalServerError), body)
+		}
+	})
+})
@@ -1,13 +1,11 @@
 package tests
 
 import (
+	"context"
 	"testing"
 	"time"
 
-	"go-demo/database"
 	"go-demo/models"
-	"go-demo/repositories"
-	"go-demo/worker"
 )
 
 // TestCleanupWorkerDeletesOldRecords inserts old and new records, runs cleanup
@@ -15,32 +13,26 @@ import (
 // newer ones remain. No real ticker or long sleeps are used.
 func TestCleanupWorkerDeletesOldRecords(t *testing.T) {
 	// Ensure created_at column exists (migration v2 runs in TestMain)
-	if database.GormDB == nil {
-		t.Fatal("database not connected")
-	}
+	requireDatabase(t)
 
 	// Create a "new" user via repository (created_at = now)
 	newUser := models.User{Name: "New Cleanup User", Role: "Tester"}
-	if err := repositories.CreateUser(newUser); err != nil {
+	if _, err := testStore.Users.Create(context.Background(), newUser); err != nil {
 		t.Fatalf("create new user: %v", err)
 	}
 
-	// Insert an "old" user via raw SQL with created_at in the past (10 days ago)
+	// Insert an "old" user with created_at in the past (10 days ago)
 	// so it will be deleted when retention is 7 days
-	err := database.GormDB.Exec(
-		`INSERT INTO users (name, role, uuid, created_at) VALUES (?, ?, gen_random_uuid(), NOW() - INTERVAL '10 days')`,
-		"Old Cleanup User",
-		"Role",
-	).Error
-	if err != nil {
+	old := models.User{Name: "Old Cleanup User", Role: "Role", CreatedAt: time.Now().Add(-10 * 24 * time.Hour)}
+	if _, err := testStore.Users.Create(context.Background(), old); err != nil {
 		t.Fatalf("insert old user: %v", err)
 	}
 
 	// Run cleanup once with 7-day retention (no ticker, no sleep)
-	worker.RunCleanupOnce(7 * 24 * time.Hour)
+	testWorkers.RunCleanupOnce(7 * 24 * time.Hour)
 
 	// Assert: old record is gone, new record remains
-	users, err := repositories.GetUsers()
+	users, err := testStore.Users.All(context.Background())
 	if err != nil {
 		t.Fatalf("get users: %v", err)
 	}
@@ -64,24 +56,22 @@ func TestCleanupWorkerDeletesOldRecords(t *testing.T) {
 // TestCleanupWorkerKeepsRecentRecords inserts two users with "recent" created_at
 // and runs cleanup with a long retention; both should remain.
 func TestCleanupWorkerKeepsRecentRecords(t *testing.T) {
-	if database.GormDB == nil {
-		t.Fatal("database not connected")
-	}
+	requireDatabase(t)
 
 	// Create two users via repository (both have created_at = now)
 	u1 := models.User{Name: "Recent User 1", Role: "A"}
 	u2 := models.User{Name: "Recent User 2", Role: "B"}
-	if err := repositories.CreateUser(u1); err != nil {
+	if _, err := testStore.Users.Create(context.Background(), u1); err != nil {
 		t.Fatalf("create user 1: %v", err)
 	}
-	if err := repositories.CreateUser(u2); err != nil {
+	if _, err := testStore.Users.Create(context.Background(), u2); err != nil {
 		t.Fatalf("create user 2: %v", err)
 	}
 
 	// Run cleanup with 1-year retention (nothing should be deleted)
-	worker.RunCleanupOnce(365 * 24 * time.Hour)
+	testWorkers.RunCleanupOnce(365 * 24 * time.Hour)
 
-	users, err := repositories.GetUsers()
+	users, err := testStore.Users.All(context.Background())
 	if err != nil {
 		t.Fatalf("get users: %v", err)
 	}
@@ -99,32 +89,26 @@ func TestCleanupWorkerKeepsRecentRecords(t *testing.T) {
 	}
 }
 
-// TestCleanupProductsDeletesOld inserts an old product via raw SQL, runs
+// TestCleanupProductsDeletesOld inserts an old product, runs
 // cleanup once, and asserts the old product is deleted.
 func TestCleanupProductsDeletesOld(t *testing.T) {
-	if database.GormDB == nil {
-		t.Fatal("database not connected")
-	}
+	requireDatabase(t)
 
 	// New product via repository
 	p := models.Product{Name: "New Product", Price: 99.99}
-	if err := repositories.CreateProduct(p); err != nil {
+	if _, err := testStore.Products.Create(context.Background(), p); err != nil {
 		t.Fatalf("create new product: %v", err)
 	}
 
-	// Old product via raw SQL (created_at 10 days ago)
-	err := database.GormDB.Exec(
-		`INSERT INTO products (name, price, uuid, created_at) VALUES (?, ?, gen_random_uuid(), NOW() - INTERVAL '10 days')`,
-		"Old Cleanup Product",
-		1.99,
-	).Error
-	if err != nil {
+	// Old product (created_at 10 days ago)
+	old := models.Product{Name: "Old Cleanup Product", Price: 1.99, CreatedAt: time.Now().Add(-10 * 24 * time.Hour)}
+	if _, err := testStore.Products.Create(context.Background(), old); err != nil {
 		t.Fatalf("insert old product: %v", err)
 	}
 
-	worker.RunCleanupOnce(7 * 24 * time.Hour)
+	testWorkers.RunCleanupOnce(7 * 24 * time.Hour)
 
-	products, err := repositories.GetProducts()
+	products, err := testStore.Products.All(context.Background())
 	if err != nil {
 		t.Fatalf("get products: %v", err)
 	}
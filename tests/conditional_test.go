@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-demo/models"
+)
+
+func TestProductConditionalRequests(t *testing.T) {
+	mux := newTestMux()
+
+	created, err := testStore.Products.Create(context.Background(), models.Product{Name: "Versioned Product", Price: 5})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	if created.Version != 1 {
+		t.Fatalf("expected version 1 on create, got %d", created.Version)
+	}
+	target := "/products/" + created.UUID
+
+	do := func(method, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// ---------- GET / If-None-Match ----------
+	rr := do(http.MethodGet, "", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1"` {
+		t.Fatalf("GET: expected 200 with ETag \"1\", got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	rr = do(http.MethodGet, "", map[string]string{"If-None-Match": `"1"`})
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("GET If-None-Match: expected 304 got %d", rr.Code)
+	}
+
+	// ---------- PUT / If-Match ----------
+	rr = do(http.MethodPut, `{"name":"Versioned Product","price":6}`, map[string]string{"If-Match": `"1"`})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("PUT If-Match: expected 200 with ETag \"2\", got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	rr = do(http.MethodPut, `{"name":"Lost Update","price":7}`, map[string]string{"If-Match": `"1"`})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("PUT stale If-Match: expected 412 got %d", rr.Code)
+	}
+	rr = do(http.MethodPatch, `{"price":8}`, map[string]string{"If-Match": `"1"`})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("PATCH stale If-Match: expected 412 got %d", rr.Code)
+	}
+
+	// ---------- DELETE / required If-Match ----------
+	testServer.RequireIfMatch = true
+	rr = do(http.MethodDelete, "", nil)
+	testServer.RequireIfMatch = false
+	if rr.Code != http.StatusPreconditionRequired {
+		t.Fatalf("DELETE without If-Match: expected 428 got %d", rr.Code)
+	}
+	rr = do(http.MethodDelete, "", map[string]string{"If-Match": `"9", "2"`})
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("DELETE If-Match: expected 204 got %d", rr.Code)
+	}
+}
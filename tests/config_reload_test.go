@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go-demo/config"
+	"go-demo/middlewares"
+)
+
+func TestConfigReloadSwapsReloadableSettings(t *testing.T) {
+	clearConfigEnv(t, "RATE_LIMIT_RPS", "RATE_LIMIT_BURST", "LOG_LEVEL", "DB_DRIVER", "DB_PATH", "CORS_ORIGINS")
+	writeConfigFile(t, "DB_DRIVER=sqlite", "DB_PATH=one.db", "RATE_LIMIT_RPS=5")
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	live := config.NewLive(cfg, nil)
+	var notified []config.Config
+	live.OnReload(func(c config.Config) { notified = append(notified, c) })
+
+	if diff, err := live.Reload(); err != nil || diff != "" {
+		t.Fatalf("reload of an unchanged file must change nothing, got %q (%v)", diff, err)
+	}
+
+	writeConfigFile(t, "DB_DRIVER=sqlite", "DB_PATH=two.db", "RATE_LIMIT_RPS=50", "CORS_ORIGINS=https://app.example")
+	diff, err := live.Reload()
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !strings.Contains(diff, "RATE_LIMIT_RPS") || !strings.Contains(diff, "https://app.example") {
+		t.Errorf("expected the diff to show the changed settings, got %q", diff)
+	}
+	got := live.Get()
+	if got.RateLimit.RPS != 50 || len(got.HTTP.CORSOrigins) != 1 || got.HTTP.CORSOrigins[0] != "https://app.example" {
+		t.Errorf("expected the reloadable settings swapped, got %+v", got)
+	}
+	if got.DB.Path != "one.db" {
+		t.Errorf("DB_PATH needs a restart and must not change on reload, got %q", got.DB.Path)
+	}
+	if len(notified) != 1 || notified[0].RateLimit.RPS != 50 {
+		t.Errorf("expected listeners told once about the new config, got %d calls", len(notified))
+	}
+
+	writeConfigFile(t, "DB_DRIVER=sqlite", "RATE_LIMIT_RPS=-1", "LOG_LEVEL=chatty")
+	if _, err := live.Reload(); err == nil {
+		t.Fatal("expected an invalid config rejected")
+	}
+	if live.Get().RateLimit.RPS != 50 {
+		t.Errorf("a rejected reload must keep the current config, got RPS %v", live.Get().RateLimit.RPS)
+	}
+}
+
+func TestConfigReloadHandler(t *testing.T) {
+	clearConfigEnv(t, "RATE_LIMIT_BURST", "DB_DRIVER")
+	writeConfigFile(t, "DB_DRIVER=sqlite", "RATE_LIMIT_BURST=10")
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	live := config.NewLive(cfg, nil)
+
+	writeConfigFile(t, "DB_DRIVER=sqlite", "RATE_LIMIT_BURST=30")
+	rr := httptest.NewRecorder()
+	live.ReloadHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var body struct {
+		Changed bool   `json:"changed"`
+		Diff    string `json:"diff"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || !body.Changed || !strings.Contains(body.Diff, "RATE_LIMIT_BURST") {
+		t.Errorf("expected the burst change reported, got %+v (%v)", body, err)
+	}
+
+	writeConfigFile(t, "DB_DRIVER=sqlite", "RATE_LIMIT_BURST=0")
+	rr = httptest.NewRecorder()
+	live.ReloadHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/config/reload", nil))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an invalid config, got %d", rr.Code)
+	}
+}
+
+func TestRateLimitFollowsReloadedLimits(t *testing.T) {
+	limits := config.RateLimit{RPS: 0.001, Burst: 1}
+	h := middlewares.RateLimitMiddleware(func() config.RateLimit { return limits }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	hit := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "198.51.100.25:4000"
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := hit(); code != http.StatusNoContent {
+		t.Fatalf("expected the first request allowed, got %d", code)
+	}
+	if code := hit(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the burst of 1 exhausted, got %d", code)
+	}
+
+	// tokens accrue at the new rate from the first request that sees it
+	limits = config.RateLimit{RPS: 1000, Burst: 100}
+	hit()
+	time.Sleep(20 * time.Millisecond)
+	if code := hit(); code != http.StatusNoContent {
+		t.Errorf("expected the raised limit applied to a client already seen, got %d", code)
+	}
+}
+
+// TestSIGHUPIgnoredAfterWatchStops checks a SIGHUP arriving while the
+// process shuts down no longer kills it.
+func TestSIGHUPIgnoredAfterWatchStops(t *testing.T) {
+	live := config.NewLive(config.Defaults(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	live.WatchSIGHUP(ctx)
+	cancel()
+	// let the watcher see the cancellation
+	time.Sleep(50 * time.Millisecond)
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("find own process: %v", err)
+	}
+	if err := self.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("send SIGHUP: %v", err)
+	}
+	// with the default action restored the test binary would be gone by now
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-demo/config"
+)
+
+// writeConfigFile writes a dotenv config file and points CONFIG_FILE at it.
+func writeConfigFile(t *testing.T, lines ...string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.env")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+// clearConfigEnv empties the variables a test asserts on, so values from
+// the developer's or CI's environment do not leak into it.
+func clearConfigEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, k := range keys {
+		t.Setenv(k, "")
+	}
+}
+
+func TestConfigLayersOverrideEachOther(t *testing.T) {
+	clearConfigEnv(t, "HTTP_ADDR", "RATE_LIMIT_BURST", "RATE_LIMIT_RPS", "WORKER_POLL_INTERVAL", "CORS_ORIGINS", "DB_DRIVER", "DB_PATH")
+	writeConfigFile(t,
+		"DB_DRIVER=sqlite",
+		"HTTP_ADDR=:9000",
+		"RATE_LIMIT_BURST=20",
+		"RATE_LIMIT_RPS=8",
+	)
+	t.Setenv("RATE_LIMIT_RPS", "12.5")
+	t.Setenv("HTTP_ADDR", ":9100")
+
+	cfg, args, err := config.Load([]string{"-http-addr=:9200", "-cors-origins", "https://a.example, https://b.example", "up"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.HTTP.Addr != ":9200" {
+		t.Errorf("flag must override env and file, got HTTP_ADDR %q", cfg.HTTP.Addr)
+	}
+	if cfg.RateLimit.RPS != 12.5 {
+		t.Errorf("env must override file, got RATE_LIMIT_RPS %v", cfg.RateLimit.RPS)
+	}
+	if cfg.RateLimit.Burst != 20 {
+		t.Errorf("file must override defaults, got RATE_LIMIT_BURST %d", cfg.RateLimit.Burst)
+	}
+	if cfg.Worker.PollInterval != config.Defaults().Worker.PollInterval {
+		t.Errorf("unset settings must keep their default, got WORKER_POLL_INTERVAL %v", cfg.Worker.PollInterval)
+	}
+	if want := []string{"https://a.example", "https://b.example"}; strings.Join(cfg.HTTP.CORSOrigins, " ") != strings.Join(want, " ") {
+		t.Errorf("expected CORS_ORIGINS %v, got %v", want, cfg.HTTP.CORSOrigins)
+	}
+	if len(args) != 1 || args[0] != "up" {
+		t.Errorf("expected the arguments after the flags returned, got %v", args)
+	}
+}
+
+func TestConfigReportsAllErrorsTogether(t *testing.T) {
+	clearConfigEnv(t, "RATE_LIMIT_RPS", "DB_DRIVER", "WORKER_POLL_INTERVAL", "CLEANUP_SCHEDULE")
+	writeConfigFile(t,
+		"RATE_LIMIT_RPS=fast",
+		"DB_DRIVER=mysql",
+		"WORKER_POLL_INTERVAL=0s",
+		"CLEANUP_SCHEDULE=sometimes",
+	)
+	_, _, err := config.Load(nil)
+	if err == nil {
+		t.Fatal("expected invalid settings to be rejected")
+	}
+	for _, key := range []string{"RATE_LIMIT_RPS", "DB_DRIVER", "WORKER_POLL_INTERVAL", "CLEANUP_SCHEDULE"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected %s among the errors, got %v", key, err)
+		}
+	}
+}
+
+func TestConfigFileIsOptionalUnlessNamed(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Chdir(t.TempDir())
+	if _, _, err := config.Load(nil); err != nil {
+		t.Fatalf("a missing default config file must not fail, got %v", err)
+	}
+	if _, _, err := config.Load([]string{"-config", "missing.env"}); err == nil {
+		t.Fatal("expected a named config file that does not exist to fail")
+	}
+}
+
+func TestConfigRedactsSecrets(t *testing.T) {
+	clearConfigEnv(t, "DB_PASSWORD", "ADMIN_TOKEN", "UNVERSIONED_SUNSET")
+	writeConfigFile(t, "DB_DRIVER=sqlite", "DB_PASSWORD=hunter2", "UNVERSIONED_SUNSET=2027-01-01T00:00:00Z")
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.HTTP.UnversionedSunset.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected UNVERSIONED_SUNSET %v", cfg.HTTP.UnversionedSunset)
+	}
+
+	dump := cfg.Redacted()
+	if dump["DB_PASSWORD"] == "hunter2" || dump["DB_PASSWORD"] == "" {
+		t.Errorf("expected DB_PASSWORD redacted, got %q", dump["DB_PASSWORD"])
+	}
+	if dump["ADMIN_TOKEN"] != "" {
+		t.Errorf("an unset secret has nothing to hide, got %q", dump["ADMIN_TOKEN"])
+	}
+	if dump["DB_DRIVER"] != "sqlite" || dump["UNVERSIONED_SUNSET"] != "2027-01-01T00:00:00Z" {
+		t.Errorf("expected other settings shown as loaded, got %v", dump)
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"go-demo/models"
+	uuidpkg "go-demo/pkg/uuid"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("List export", func() {
+	var (
+		mux   *http.ServeMux
+		token string
+	)
+
+	BeforeEach(func() {
+		mux = newTestMux()
+		token = "export" + uuidpkg.New()[:8]
+		for _, price := range []float64{3, 1, 2} {
+			_, err := testStore.Products.Create(context.Background(), models.Product{
+				Name:  "=HYPERLINK(" + token + ")",
+				Price: price,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+	})
+
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	It("streams filtered, sorted products as CSV", func() {
+		rr := get("/products?sort=-price&name_contains="+token, "text/csv")
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(rr.Header().Get("Content-Type")).To(HavePrefix("text/csv"))
+		Expect(rr.Header().Get("Content-Disposition")).To(ContainSubstring("products.csv"))
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(4))
+		Expect(records[0]).To(Equal([]string{"uuid", "name", "price", "version", "created_at", "deleted_at"}))
+		Expect([]string{records[1][2], records[2][2], records[3][2]}).To(Equal([]string{"3", "2", "1"}))
+		// formula-like cells are neutralised for spreadsheets
+		Expect(records[1][1]).To(Equal("'=HYPERLINK(" + token + ")"))
+	})
+
+	It("streams products as NDJSON and honours limit", func() {
+		rr := get("/products?sort=price&limit=2&name_contains="+token, "application/x-ndjson")
+		Expect(rr.Code).To(Equal(http.StatusOK))
+
+		var prices []float64
+		scanner := bufio.NewScanner(rr.Body)
+		for scanner.Scan() {
+			var p models.Product
+			Expect(json.Unmarshal(scanner.Bytes(), &p)).To(Succeed())
+			prices = append(prices, p.Price)
+		}
+		Expect(prices).To(Equal([]float64{1, 2}))
+	})
+
+	It("prefers the highest quality type and defaults to JSON", func() {
+		rr := get("/users?limit=1", "text/csv;q=0.5, application/x-ndjson;q=0.9")
+		Expect(rr.Header().Get("Content-Type")).To(HavePrefix("application/x-ndjson"))
+
+		rr = get("/users?limit=1", "*/*")
+		Expect(rr.Header().Get("Content-Type")).To(Equal("application/json"))
+	})
+
+	It("rejects unsupported types and bad sorts before streaming", func() {
+		Expect(get("/products", "image/png").Code).To(Equal(http.StatusNotAcceptable))
+		Expect(get("/products?sort=colour", "text/csv").Code).To(Equal(http.StatusBadRequest))
+	})
+})
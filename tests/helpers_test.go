@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"go-demo/config"
+	"go-demo/handlers"
+	"go-demo/models"
+	"go-demo/repositories"
+	"go-demo/worker"
+
+	"gorm.io/gorm"
+)
+
+// Built once by TestMain and shared by every test in the package. With
+// TEST_BACKEND=memory, testMemory backs testStore, testDB stays nil and the
+// workers that poll the database are not started.
+var (
+	testConfig  config.Config
+	testDB      *gorm.DB
+	testMemory  *repositories.Memory
+	testStore   repositories.Store
+	testServer  *handlers.Server
+	testWorkers *worker.Workers
+)
+
+func newTestMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	testServer.RegisterRoutes(mux)
+	return mux
+}
+
+// requireDatabase skips tests that need raw SQL or the workers.
+func requireDatabase(t testing.TB) {
+	t.Helper()
+	if testDB == nil {
+		t.Skip("needs a database; running against the in-memory store")
+	}
+}
+
+// auditEvents returns the audit events recorded for entityUUID, oldest
+// first, from whichever backend the tests run against.
+func auditEvents(entityUUID string) ([]models.AuditLog, error) {
+	if testMemory != nil {
+		return slices.DeleteFunc(testMemory.AuditLogs(), func(ev models.AuditLog) bool {
+			return ev.EntityUUID != entityUUID
+		}), nil
+	}
+	var events []models.AuditLog
+	err := testDB.Where("entity_uuid = ?", entityUUID).Order("id").Find(&events).Error
+	return events, err
+}
+
+// outboxJobs returns the notification jobs enqueued for entityUUID, oldest
+// first, from whichever backend the tests run against.
+func outboxJobs(entityUUID string) ([]models.NotificationOutbox, error) {
+	if testMemory != nil {
+		return slices.DeleteFunc(testMemory.NotificationOutbox(), func(job models.NotificationOutbox) bool {
+			return job.EntityUUID != entityUUID
+		}), nil
+	}
+	var jobs []models.NotificationOutbox
+	err := testDB.Where("entity_uuid = ?", entityUUID).Order("id").Find(&jobs).Error
+	return jobs, err
+}
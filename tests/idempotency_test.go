@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-demo/middlewares"
+)
+
+func TestIdempotentUserCreate(t *testing.T) {
+	handler := middlewares.IdempotencyMiddleware(testStore.Idempotency, newTestMux())
+	key := "test-key-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := post(`{"name":"Idempotent User","role":"Tester"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first POST: expected 201 got %d", first.Code)
+	}
+
+	retry := post(`{"name":"Idempotent User","role":"Tester"}`)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry: expected replayed 201, got %d replayed=%q", retry.Code, retry.Header().Get("Idempotent-Replayed"))
+	}
+	if retry.Body.String() != first.Body.String() || retry.Header().Get("Location") != first.Header().Get("Location") {
+		t.Fatalf("retry must replay the original response:\nfirst: %s\nretry: %s", first.Body.String(), retry.Body.String())
+	}
+
+	mismatch := post(`{"name":"Someone Else","role":"Tester"}`)
+	if mismatch.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key with different body: expected 422 got %d", mismatch.Code)
+	}
+
+	// Expired keys are removed by the cleanup job.
+	testWorkers.RunIdempotencyCleanupOnce(time.Now().Add(48 * time.Hour))
+	claimed, existing, err := testStore.Idempotency.Claim(context.Background(), key, "fingerprint", time.Hour)
+	if err != nil || !claimed {
+		t.Fatalf("expected expired key to be cleaned up, found %+v (%v)", existing, err)
+	}
+}
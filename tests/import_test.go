@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"go-demo/models"
+	uuidpkg "go-demo/pkg/uuid"
+	"go-demo/repositories"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type importReportBody struct {
+	DryRun   bool                        `json:"dry_run"`
+	Created  int                         `json:"created"`
+	Updated  int                         `json:"updated"`
+	Rejected int                         `json:"rejected"`
+	Rows     []repositories.ImportResult `json:"rows"`
+}
+
+var _ = Describe("Product import", func() {
+	var (
+		mux   *http.ServeMux
+		token string
+	)
+
+	BeforeEach(func() {
+		mux = newTestMux()
+		token = "import" + uuidpkg.New()[:8]
+	})
+
+	post := func(target, contentType, body string) (*httptest.ResponseRecorder, importReportBody) {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		var report importReportBody
+		json.Unmarshal(rr.Body.Bytes(), &report)
+		return rr, report
+	}
+
+	countByName := func(name string) int {
+		page, err := testStore.Products.List(context.Background(), repositories.ProductQuery{NameContains: name})
+		Expect(err).NotTo(HaveOccurred())
+		return len(page.Items)
+	}
+
+	It("creates, updates by name and rejects invalid CSV rows", func() {
+		existing, err := testStore.Products.Create(context.Background(), models.Product{Name: token + " old", Price: 1})
+		Expect(err).NotTo(HaveOccurred())
+
+		csv := "name,price\n" +
+			token + " new,9.5\n" +
+			token + " old,2\n" +
+			token + " free,0\n" +
+			token + " odd,abc\n"
+		rr, report := post("/products/import", "text/csv", csv)
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(report.Created).To(Equal(1))
+		Expect(report.Updated).To(Equal(1))
+		Expect(report.Rejected).To(Equal(2))
+		Expect(report.Rows).To(HaveLen(4))
+		Expect(report.Rows[1].UUID).To(Equal(existing.UUID))
+		Expect(report.Rows[2].Line).To(Equal(4))
+		Expect(report.Rows[2].Reason).To(ContainSubstring("price"))
+		Expect(report.Rows[3].Reason).To(ContainSubstring("not a number"))
+
+		updated, err := testStore.Products.Get(context.Background(), existing.UUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Price).To(Equal(2.0))
+		Expect(updated.Version).To(Equal(existing.Version + 1))
+	})
+
+	It("writes nothing on a dry run", func() {
+		rr, report := post("/products/import?dry_run=true", "text/csv", "name,price\n"+token+",5\n")
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(report.DryRun).To(BeTrue())
+		Expect(report.Created).To(Equal(1))
+		Expect(countByName(token)).To(Equal(0))
+	})
+
+	It("upserts NDJSON rows by uuid and reports malformed lines", func() {
+		id := uuidpkg.New()
+		ndjson := `{"uuid":"` + id + `","name":"` + token + `","price":3}` + "\n" +
+			"\n" +
+			`{"name":` + "\n" +
+			`{"uuid":"` + id + `","name":"` + token + `","price":4}` + "\n"
+		rr, report := post("/products/import", "application/x-ndjson", ndjson)
+
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect([]int{report.Created, report.Updated, report.Rejected}).To(Equal([]int{1, 1, 1}))
+		Expect(report.Rows[1].Line).To(Equal(3))
+
+		stored, err := testStore.Products.Get(context.Background(), id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored.Price).To(Equal(4.0))
+	})
+
+	It("re-imports its own CSV export", func() {
+		_, err := testStore.Products.Create(context.Background(), models.Product{Name: "=" + token, Price: 7})
+		Expect(err).NotTo(HaveOccurred())
+
+		req := httptest.NewRequest(http.MethodGet, "/products?name_contains="+token, nil)
+		req.Header.Set("Accept", "text/csv")
+		export := httptest.NewRecorder()
+		mux.ServeHTTP(export, req)
+
+		rr, report := post("/products/import", "text/csv", export.Body.String())
+		Expect(rr.Code).To(Equal(http.StatusOK))
+		Expect(report.Updated).To(Equal(1))
+		Expect(report.Rejected).To(Equal(0))
+
+		stored, err := testStore.Products.Get(context.Background(), report.Rows[0].UUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored.Name).To(Equal("=" + token))
+	})
+
+	It("reports the committed rows when the file breaks after a batch", func() {
+		var csv strings.Builder
+		csv.WriteString("name,price\n")
+		for i := range 501 {
+			fmt.Fprintf(&csv, "%s %d,1\n", token, i)
+		}
+		csv.WriteString(token + " broken,\"2\n")
+		rr, _ := post("/products/import", "text/csv", csv.String())
+
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+		var prob struct {
+			Status int              `json:"status"`
+			Report importReportBody `json:"report"`
+		}
+		Expect(json.Unmarshal(rr.Body.Bytes(), &prob)).To(Succeed())
+		Expect(prob.Status).To(Equal(http.StatusBadRequest))
+		// the first batch was committed, the row after it was not
+		Expect(prob.Report.Created).To(Equal(500))
+		Expect(prob.Report.Rows).To(HaveLen(500))
+		Expect(prob.Report.Rows[499].Line).To(Equal(501))
+		Expect(prob.Report.Rows[499].UUID).NotTo(BeEmpty())
+		page, err := testStore.Products.List(context.Background(), repositories.ProductQuery{
+			ListParams: repositories.ListParams{IncludeTotal: true}, NameContains: token})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*page.Total).To(Equal(int64(500)))
+	})
+
+	It("rejects unsupported files outright", func() {
+		rr, _ := post("/products/import", "application/json", `[]`)
+		Expect(rr.Code).To(Equal(http.StatusUnsupportedMediaType))
+
+		rr, _ = post("/products/import", "text/csv", "name,colour\nx,red\n")
+		Expect(rr.Code).To(Equal(http.StatusBadRequest))
+	})
+})
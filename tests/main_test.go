@@ -1,29 +1,63 @@
 package tests
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"go-demo/app"
 	"go-demo/config"
-	"go-demo/database"
+	"go-demo/database/migrations"
+	"go-demo/handlers"
 	"go-demo/pkg/logger"
 	"go-demo/pkg/validator"
+	"go-demo/repositories"
 	"go-demo/worker"
 )
 
 func TestMain(m *testing.M) {
-	// Ensure test env is loaded before connecting to DB
+	// Ensure test env is loaded before connecting to DB; tests run from
+	// tests/, so the file is named explicitly unless overridden
 	os.Setenv("APP_ENV", "test")
-	config.LoadEnv()
-	logger.Init()
+	if os.Getenv("CONFIG_FILE") == "" {
+		os.Setenv("CONFIG_FILE", filepath.Join("..", ".env.test"))
+	}
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("invalid test configuration")
+	}
+	testConfig = cfg
+	logger.Init(cfg.LogLevel)
 	validator.Init()
-	database.Connect()
 
-	// start audit worker in test binary so audit events are processed here too
-	worker.StartWorker()
+	if os.Getenv("TEST_BACKEND") == "memory" {
+		// No database: run against the in-memory store. Tests that need
+		// raw SQL or the polling workers skip themselves.
+		testMemory = repositories.NewMemory()
+		testStore = testMemory.Store()
+		testWorkers = worker.New(nil, testStore, cfg.Worker)
+	} else {
+		a := app.New(cfg.DB)
+		testDB, testStore = a.DB, a.Store
 
-	// start notification worker in test binary so notifications are processed here too
-	worker.StartNotificationWorker()
+		// Connect leaves the schema alone; bring it up to date here
+		m, err := migrations.New(a.DB)
+		if err != nil {
+			logger.Log.Fatal().Err(err).Msg("failed to load migrations")
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			logger.Log.Fatal().Err(err).Msg("failed to migrate test database")
+		}
+		testWorkers = worker.New(a.DB, a.Store, cfg.Worker)
+
+		// start audit worker in test binary so audit events are processed here too
+		testWorkers.StartAuditWorker(context.Background())
+
+		// start notification worker in test binary so notifications are processed here too
+		testWorkers.StartNotificationWorker(context.Background())
+	}
+	testServer = handlers.NewServer(testStore)
 
 	// Run tests
 	code := m.Run()
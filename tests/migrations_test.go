@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go-demo/database"
+	"go-demo/database/drift"
+	"go-demo/database/migrations"
+	"go-demo/models"
+
+	"gorm.io/gorm"
+)
+
+// newMigrationDB opens an empty SQLite database, so the runner can be taken
+// up and down without touching the shared test database.
+func newMigrationDB(t *testing.T) (*gorm.DB, *migrations.Migrator) {
+	t.Helper()
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	m, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	return db, m
+}
+
+func TestMigrationsUpDownRedo(t *testing.T) {
+	ctx := context.Background()
+	db, m := newMigrationDB(t)
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) == 0 {
+		t.Fatalf("up: applied %d migrations (%v)", len(applied), err)
+	}
+	if again, err := m.Up(ctx); err != nil || len(again) != 0 {
+		t.Fatalf("second up must be a no-op, applied %d (%v)", len(again), err)
+	}
+	if err := db.Create(&models.User{Name: "Migrated", Role: "Tester"}).Error; err != nil {
+		t.Fatalf("migrated schema must fit the models: %v", err)
+	}
+
+	latest := applied[len(applied)-1]
+	reverted, err := m.Down(ctx, 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != latest.Version {
+		t.Fatalf("down 1: expected to roll back %d, got %+v (%v)", latest.Version, reverted, err)
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Version != latest.Version {
+		t.Fatalf("expected %d pending after down, got %+v (%v)", latest.Version, pending, err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up after down: %v", err)
+	}
+	redone, err := m.Redo(ctx)
+	if err != nil || redone.Version != latest.Version {
+		t.Fatalf("redo: expected %d, got %d (%v)", latest.Version, redone.Version, err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil || len(statuses) != len(applied) {
+		t.Fatalf("status: got %+v (%v)", statuses, err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil || s.Modified || s.Missing {
+			t.Errorf("expected %04d_%s applied and unchanged, got %+v", s.Version, s.Name, s)
+		}
+	}
+
+	if _, err := m.Down(ctx, len(applied)); err != nil {
+		t.Fatalf("down all: %v", err)
+	}
+	if db.Migrator().HasTable("users") {
+		t.Error("expected down all to drop the users table")
+	}
+}
+
+func TestMigrationsRejectEditedScripts(t *testing.T) {
+	ctx := context.Background()
+	db, m := newMigrationDB(t)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	if err := db.Exec(`UPDATE schema_versions SET checksum = 'edited' WHERE version = 1`).Error; err != nil {
+		t.Fatalf("tamper checksum: %v", err)
+	}
+	if _, err := m.Up(ctx); !errors.Is(err, migrations.ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil || !statuses[0].Modified {
+		t.Fatalf("expected status to flag the edited migration, got %+v (%v)", statuses, err)
+	}
+
+	if err := db.Exec(`INSERT INTO schema_versions (version, name, checksum, applied_at) VALUES (9999, 'from_the_future', 'x', CURRENT_TIMESTAMP)`).Error; err != nil {
+		t.Fatalf("insert unknown version: %v", err)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, migrations.ErrChecksumMismatch) && !errors.Is(err, migrations.ErrUnknownVersion) {
+		t.Fatalf("expected down to refuse a database it cannot verify, got %v", err)
+	}
+}
+
+// legacySchema is what the last release's boot-time auto_migrate_v1..v3
+// left behind: no version or deleted_at columns, no unique UUID index, no
+// entity_uuid on the queues and no idempotency_keys table.
+var legacySchema = []string{
+	`CREATE TABLE schema_migrations (version TEXT PRIMARY KEY, applied_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+	`INSERT INTO schema_migrations (version) VALUES ('auto_migrate_v1'), ('auto_migrate_v2'), ('auto_migrate_v3')`,
+	`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, uuid TEXT, name TEXT NOT NULL, role TEXT NOT NULL, created_at DATETIME)`,
+	`CREATE TABLE products (id INTEGER PRIMARY KEY AUTOINCREMENT, uuid TEXT, name TEXT NOT NULL, price REAL NOT NULL, created_at DATETIME)`,
+	`CREATE TABLE audit_logs (id INTEGER PRIMARY KEY AUTOINCREMENT, action TEXT NOT NULL, entity TEXT NOT NULL, entity_id INTEGER NOT NULL,
+		message TEXT NOT NULL, timestamp DATETIME NOT NULL, processed_at DATETIME, created_at DATETIME)`,
+	`CREATE INDEX idx_audit_logs_processed_at ON audit_logs (processed_at)`,
+	`CREATE TABLE notification_jobs (id INTEGER PRIMARY KEY AUTOINCREMENT, type TEXT NOT NULL, recipient TEXT NOT NULL, message TEXT NOT NULL,
+		status TEXT DEFAULT 'PENDING', processed_at DATETIME, error TEXT, created_at DATETIME)`,
+	`CREATE INDEX idx_notification_jobs_status ON notification_jobs (status)`,
+	`CREATE TABLE notification_outboxes (id INTEGER PRIMARY KEY AUTOINCREMENT, event_type TEXT NOT NULL, payload TEXT NOT NULL,
+		status TEXT DEFAULT 'PENDING', processed_at DATETIME, error TEXT, created_at DATETIME)`,
+	`CREATE INDEX idx_notification_outboxes_status ON notification_outboxes (status)`,
+	`INSERT INTO users (uuid, name, role, created_at) VALUES ('6f1c2a9e-3b0d-4c57-9a8e-1d2f3a4b5c6d', 'Legacy', 'Tester', CURRENT_TIMESTAMP)`,
+}
+
+func TestMigrationsAdoptLegacySchema(t *testing.T) {
+	ctx := context.Background()
+	db, m := newMigrationDB(t)
+	for _, stmt := range legacySchema {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatalf("up over legacy schema: %v", err)
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("expected the legacy schema adopted, %d pending (%v)", len(pending), err)
+	}
+	migr := db.Migrator()
+	for _, table := range []string{"users", "products"} {
+		for _, column := range []string{"version", "deleted_at"} {
+			if !migr.HasColumn(table, column) {
+				t.Errorf("expected %s.%s added to the legacy schema", table, column)
+			}
+		}
+		for _, index := range []string{"idx_" + table + "_uuid", "idx_" + table + "_deleted_at"} {
+			if !migr.HasIndex(table, index) {
+				t.Errorf("expected index %s added to the legacy schema", index)
+			}
+		}
+	}
+	for _, table := range []string{"audit_logs", "notification_outboxes"} {
+		if !migr.HasColumn(table, "entity_uuid") {
+			t.Errorf("expected %s.entity_uuid added to the legacy schema", table)
+		}
+	}
+	if !migr.HasTable("idempotency_keys") {
+		t.Error("expected idempotency_keys created on the legacy schema")
+	}
+	drifts, err := drift.Check(ctx, db, drift.Models...)
+	if err != nil {
+		t.Fatalf("drift check: %v", err)
+	}
+	for _, d := range drifts {
+		t.Errorf("adopted legacy schema drifts from the models: %s", d)
+	}
+
+	var legacy models.User
+	if err := db.First(&legacy, "name = ?", "Legacy").Error; err != nil || legacy.Version != 1 {
+		t.Errorf("expected the existing user kept at version 1, got %+v (%v)", legacy, err)
+	}
+}
+
+// TestMigrationsAppliedToTestDatabase checks TestMain left the shared
+// database fully migrated.
+func TestMigrationsAppliedToTestDatabase(t *testing.T) {
+	requireDatabase(t)
+	m, err := migrations.New(testDB)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil || s.Modified || s.Missing {
+			t.Errorf("expected %04d_%s applied and unchanged, got %+v", s.Version, s.Name, s)
+		}
+	}
+}
@@ -1,20 +1,19 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 	"time"
 
-	"go-demo/database"
 	"go-demo/models"
-	"go-demo/repositories"
-	"go-demo/worker"
 
 	. "github.com/onsi/gomega"
 )
 
 // TestNotificationWorkerEnqueue verifies that a notification job is persisted to the database.
 func TestNotificationWorkerEnqueue(t *testing.T) {
+	requireDatabase(t)
 	RegisterTestingT(t)
 
 	// Create and enqueue a test notification job via Outbox
@@ -25,13 +24,13 @@ func TestNotificationWorkerEnqueue(t *testing.T) {
 	payloadBytes, _ := json.Marshal(payloadMap)
 
 	// Use repository to create outbox entry
-	repositories.CreateNotificationOutbox("WELCOME_EMAIL", string(payloadBytes))
+	Expect(testStore.Outbox.Enqueue(context.Background(), "WELCOME_EMAIL", "", string(payloadBytes))).To(Succeed())
 
 	// Verify it exists in DB
 	var savedJob models.NotificationOutbox
 	// We need to parse the JSON payload in a real app, but for this test checking persistence is enough
 	// or we can use LIKE query if we don't want to parse
-	err := database.GormDB.Where("payload LIKE ?", "%test_enqueue@example.com%").Last(&savedJob).Error
+	err := testDB.Where("payload LIKE ?", "%test_enqueue@example.com%").Last(&savedJob).Error
 	Expect(err).To(BeNil())
 	Expect(savedJob.EventType).To(Equal("WELCOME_EMAIL"))
 	Expect(savedJob.Status).To(Equal("PENDING"))
@@ -39,10 +38,13 @@ func TestNotificationWorkerEnqueue(t *testing.T) {
 
 // TestNotificationWorkerProcessesJob verifies that if we run the worker logic, it processes the job.
 func TestNotificationWorkerProcessesJob(t *testing.T) {
+	requireDatabase(t)
 	RegisterTestingT(t)
 
-	// Start the worker (it will poll every second)
-	worker.StartNotificationWorker()
+	// Start the worker (it will poll every second) for the length of the test
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	testWorkers.StartNotificationWorker(ctx)
 
 	// Enqueue a job via Outbox
 	payloadMap := map[string]string{
@@ -50,17 +52,17 @@ func TestNotificationWorkerProcessesJob(t *testing.T) {
 		"message":   "Reset your password",
 	}
 	payloadBytes, _ := json.Marshal(payloadMap)
-	repositories.CreateNotificationOutbox("RESET_PASSWORD", string(payloadBytes))
+	Expect(testStore.Outbox.Enqueue(context.Background(), "RESET_PASSWORD", "", string(payloadBytes))).To(Succeed())
 
 	// Wait for worker to pick it up (poll interval is 1s)
 	Eventually(func() string {
 		var j models.NotificationOutbox
-		database.GormDB.Where("payload LIKE ?", "%process_test@example.com%").Last(&j)
+		testDB.Where("payload LIKE ?", "%process_test@example.com%").Last(&j)
 		return j.Status
 	}, 3*time.Second, 500*time.Millisecond).Should(Equal("DONE"))
 
 	// Verify ProcessedAt is set
 	var j models.NotificationOutbox
-	database.GormDB.Where("payload LIKE ?", "%process_test@example.com%").Last(&j)
+	testDB.Where("payload LIKE ?", "%process_test@example.com%").Last(&j)
 	Expect(j.ProcessedAt).NotTo(BeNil())
 }
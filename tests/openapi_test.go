@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-demo/handlers"
+	"go-demo/pkg/openapi"
+)
+
+// assertMatchesSpec fails when the recorded response uses a status the
+// operation does not document or a body that does not match its schema.
+func assertMatchesSpec(t *testing.T, req *http.Request, rr *httptest.ResponseRecorder) {
+	t.Helper()
+	doc := handlers.Spec()
+
+	op, _ := doc.Find(req.Method, req.URL.Path)
+	if op == nil {
+		t.Fatalf("%s %s is not documented", req.Method, req.URL.Path)
+	}
+	resp, ok := op.Responses[strconv.Itoa(rr.Code)]
+	if !ok {
+		t.Fatalf("%s %s: status %d is not documented", req.Method, req.URL.Path, rr.Code)
+	}
+	if len(resp.Content) == 0 {
+		return
+	}
+
+	contentType := strings.TrimSpace(strings.Split(rr.Header().Get("Content-Type"), ";")[0])
+	media, ok := resp.Content[contentType]
+	if !ok {
+		t.Fatalf("%s %s: %d answered with undocumented content type %q", req.Method, req.URL.Path, rr.Code, contentType)
+	}
+	if v := doc.ValidateJSON(media.Schema, rr.Body.Bytes()); len(v) > 0 {
+		t.Fatalf("%s %s: %d body does not match spec: %v\n%s", req.Method, req.URL.Path, rr.Code, v, rr.Body.String())
+	}
+}
+
+func TestOpenAPIDocumentServed(t *testing.T) {
+	mux := newTestMux()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rr.Code)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Fatalf("expected openapi %s, got %s", openapi.Version, doc.OpenAPI)
+	}
+
+	product := doc.Components.Schemas["Product"]
+	if product == nil || product.Properties["price"].ExclusiveMinimum == nil || *product.Properties["price"].ExclusiveMinimum != 0 {
+		t.Fatalf("expected price > 0 constraint from validate tag, got %+v", product)
+	}
+	if _, ok := product.Properties["id"]; ok {
+		t.Fatal("serial id must not be documented")
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "openapi.json") {
+		t.Fatalf("expected docs page, got %d", rr.Code)
+	}
+}
+
+// Every documented operation must reach the mux pattern of the same name.
+func TestOpenAPIOperationsAreRouted(t *testing.T) {
+	mux := newTestMux()
+	doc := handlers.Spec()
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			target := strings.ReplaceAll(path, "{id}", "00000000-0000-4000-8000-000000000000")
+			req := httptest.NewRequest(strings.ToUpper(method), target, nil)
+
+			_, pattern := mux.Handler(req)
+			if want := strings.ToUpper(method) + " " + path; pattern != want {
+				t.Errorf("%s %s is documented but routed to %q", strings.ToUpper(method), path, pattern)
+			}
+		}
+	}
+}
+
+func TestOpenAPIResponsesMatchSpec(t *testing.T) {
+	mux := newTestMux()
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		assertMatchesSpec(t, req, rr)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/users", `{"name":"Spec User","role":"Tester"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rr.Code)
+	}
+	var user struct {
+		UUID string `json:"uuid"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &user)
+
+	serve(http.MethodGet, "/users/"+user.UUID, "")
+	serve(http.MethodGet, "/users?limit=1&include_total=true", "")
+	serve(http.MethodPatch, "/users/"+user.UUID, `{"role":"Admin"}`)
+	serve(http.MethodPost, "/users", `{"name":""}`)
+	serve(http.MethodGet, "/users?bogus=1", "")
+	serve(http.MethodDelete, "/users/"+user.UUID, "")
+	serve(http.MethodPost, "/users/"+user.UUID+"/restore", "")
+
+	rr = serve(http.MethodPost, "/products", `{"name":"Spec Product","price":9.5}`)
+	var product struct {
+		UUID string `json:"uuid"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &product)
+
+	serve(http.MethodGet, "/products/"+product.UUID, "")
+	serve(http.MethodGet, "/products?sort=-price", "")
+	serve(http.MethodPut, "/products/"+product.UUID, `{"name":"Spec Product","price":0}`)
+	serve(http.MethodGet, "/products/00000000-0000-4000-8000-000000000000", "")
+	serve(http.MethodGet, "/products/not-a-uuid", "")
+}
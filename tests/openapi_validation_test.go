@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-demo/handlers"
+	"go-demo/middlewares"
+	"go-demo/pkg/logger"
+	"go-demo/pkg/problem"
+
+	"github.com/rs/zerolog"
+)
+
+func TestOpenAPIValidationRejectsBadRequests(t *testing.T) {
+	reached := false
+	handler := middlewares.OpenAPIValidationMiddleware(handlers.Spec(), false, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cases := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"invalid path uuid", http.MethodGet, "/users/not-a-uuid", "", "", http.StatusBadRequest},
+		{"non-integer limit", http.MethodGet, "/products?limit=many", "", "", http.StatusBadRequest},
+		{"unknown sort", http.MethodGet, "/products?sort=colour", "", "", http.StatusBadRequest},
+		{"undocumented query", http.MethodGet, "/users?bogus=1", "", "", http.StatusBadRequest},
+		{"wrong content type", http.MethodPost, "/users", "text/plain", `{}`, http.StatusUnsupportedMediaType},
+		{"wrong field type", http.MethodPost, "/products", "application/json", `{"name":"x","price":"cheap"}`, http.StatusBadRequest},
+		{"constraint violated", http.MethodPost, "/products", "application/json", `{"name":"x","price":0}`, http.StatusUnprocessableEntity},
+		{"missing required", http.MethodPost, "/users", "application/json", `{"name":"x"}`, http.StatusUnprocessableEntity},
+		{"bad json patch op", http.MethodPatch, "/users/00000000-0000-4000-8000-000000000000", "application/json-patch+json", `[{"op":"frobnicate","path":"/name"}]`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.status {
+				t.Fatalf("expected %d got %d: %s", tc.status, rr.Code, rr.Body.String())
+			}
+			if reached {
+				t.Fatal("invalid request reached the handler")
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Fatalf("expected problem+json, got %q", ct)
+			}
+		})
+	}
+
+	// A rejected field is reported the way handler validation reports it.
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(`{"name":"x","price":-1}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	var p problem.Problem
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "price" || p.Errors[0].Tag != "exclusiveMinimum" {
+		t.Fatalf("unexpected field errors: %+v", p.Errors)
+	}
+}
+
+func TestOpenAPIValidationPassesValidRequests(t *testing.T) {
+	var gotBody string
+	handler := middlewares.OpenAPIValidationMiddleware(handlers.Spec(), false, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := new(bytes.Buffer)
+		b.ReadFrom(r.Body)
+		gotBody = b.String()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	body := `{"name":"Valid","price":1.5}`
+	req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected request to reach handler, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if gotBody != body {
+		t.Fatalf("handler saw body %q, want %q", gotBody, body)
+	}
+
+	// Paths outside the contract are not touched.
+	req = httptest.NewRequest(http.MethodGet, "/openapi.json?anything=1", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected undocumented path to pass through, got %d", rr.Code)
+	}
+}
+
+func TestOpenAPIValidationLogsResponseViolations(t *testing.T) {
+	var logs bytes.Buffer
+	saved := logger.Log
+	logger.Log = zerolog.New(&logs)
+	t.Cleanup(func() { logger.Log = saved })
+
+	handler := middlewares.OpenAPIValidationMiddleware(handlers.Spec(), true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"uuid":"00000000-0000-4000-8000-000000000000","name":"x","price":"free","id":7}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/products/00000000-0000-4000-8000-000000000000", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"free"`) {
+		t.Fatalf("response must reach the client unchanged, got %d %s", rr.Code, rr.Body.String())
+	}
+	out := logs.String()
+	for _, want := range []string{"response violates OpenAPI contract", "getProduct", "/price", "/id"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected log to mention %q, got %s", want, out)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"go-demo/models"
+	uuidpkg "go-demo/pkg/uuid"
+	"go-demo/repositories"
+	"go-demo/worker"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transactional outbox", func() {
+	ctx := context.Background()
+
+	It("commits the user with its audit event and welcome email", func() {
+		req := httptest.NewRequest(http.MethodPost, "/users",
+			bytes.NewBufferString(`{"name":"Outbox User","role":"Buyer"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		newTestMux().ServeHTTP(rr, req)
+		Expect(rr.Code).To(Equal(http.StatusCreated))
+
+		var user models.User
+		Expect(json.Unmarshal(rr.Body.Bytes(), &user)).To(Succeed())
+		Expect(auditEvents(user.UUID)).To(HaveLen(1))
+
+		jobs, err := outboxJobs(user.UUID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(jobs).To(HaveLen(1))
+		Expect(jobs[0].EventType).To(Equal("WELCOME_EMAIL"))
+		Expect(jobs[0].Status).To(Equal("PENDING"))
+	})
+
+	It("rolls the entity back when a side effect fails", func() {
+		id := uuidpkg.New()
+		boom := errors.New("side effect failed")
+		err := testStore.Tx.Transaction(ctx, func(ctx context.Context) error {
+			if _, err := testStore.Users.Create(ctx, models.User{UUID: id, Name: "Rolled Back", Role: "Buyer"}); err != nil {
+				return err
+			}
+			if err := testStore.Audit.Publish(ctx, worker.NewEvent("CREATE", "user", id, "created user")); err != nil {
+				return err
+			}
+			if err := testStore.Outbox.Enqueue(ctx, "WELCOME_EMAIL", id, "{}"); err != nil {
+				return err
+			}
+			return boom
+		})
+		Expect(err).To(MatchError(boom))
+
+		_, err = testStore.Users.GetIncludingDeleted(ctx, id)
+		Expect(err).To(MatchError(repositories.ErrNotFound))
+		Expect(auditEvents(id)).To(BeEmpty())
+		Expect(outboxJobs(id)).To(BeEmpty())
+	})
+
+	It("nests a unit of work as a savepoint of the outer one", func() {
+		kept, dropped := uuidpkg.New(), uuidpkg.New()
+		err := testStore.Tx.Transaction(ctx, func(ctx context.Context) error {
+			if _, err := testStore.Products.Create(ctx, models.Product{UUID: kept, Name: "Kept", Price: 1}); err != nil {
+				return err
+			}
+			inner := testStore.Tx.Transaction(ctx, func(ctx context.Context) error {
+				if _, err := testStore.Products.Create(ctx, models.Product{UUID: dropped, Name: "Dropped", Price: 1}); err != nil {
+					return err
+				}
+				return errors.New("inner failed")
+			})
+			Expect(inner).To(HaveOccurred())
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = testStore.Products.Get(ctx, kept)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = testStore.Products.Get(ctx, dropped)
+		Expect(err).To(MatchError(repositories.ErrNotFound))
+	})
+})
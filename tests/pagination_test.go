@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go-demo/models"
+	"go-demo/repositories"
+)
+
+func TestProductListKeysetPagination(t *testing.T) {
+	mux := newTestMux()
+
+	prices := []float64{30, 10, 50, 20, 40}
+	for _, price := range prices {
+		if _, err := testStore.Products.Create(context.Background(), models.Product{Name: "Paged Product", Price: price}); err != nil {
+			t.Fatalf("create product: %v", err)
+		}
+	}
+
+	var seen []float64
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(prices) {
+			t.Fatal("pagination did not terminate")
+		}
+
+		q := url.Values{}
+		q.Set("name_contains", "paged product")
+		q.Set("price_gte", "20")
+		q.Set("sort", "-price")
+		q.Set("limit", "2")
+		q.Set("include_total", "true")
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/products?"+q.Encode(), nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET products page failed, expected 200 got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		var page repositories.Page[models.Product]
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatalf("decode page: %v", err)
+		}
+		if page.Total == nil || *page.Total < 4 {
+			t.Fatalf("expected total of at least 4, got %v", page.Total)
+		}
+		for _, p := range page.Items {
+			seen = append(seen, p.Price)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	for i := 1; i < len(seen); i++ {
+		if seen[i] > seen[i-1] {
+			t.Fatalf("expected prices in descending order, got %v", seen)
+		}
+	}
+	for _, p := range seen {
+		if p < 20 {
+			t.Fatalf("price_gte filter not applied, got %v", seen)
+		}
+	}
+}
+
+func TestListRejectsBadParameters(t *testing.T) {
+	mux := newTestMux()
+
+	for _, target := range []string{
+		"/users?cursor=not-a-cursor",
+		"/users?sort=password",
+		"/users?limit=0",
+		"/users?created_after=yesterday",
+		"/products?price_gte=cheap",
+		"/products?unknown=1",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: expected 400 got %d", target, rr.Code)
+		}
+	}
+}
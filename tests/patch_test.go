@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-demo/models"
+)
+
+func TestProductPatchFormats(t *testing.T) {
+	mux := newTestMux()
+
+	created, err := testStore.Products.Create(context.Background(), models.Product{Name: "Patched Product", Price: 10})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	target := "/products/" + created.UUID
+
+	patch := func(contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// ---------- MERGE PATCH ----------
+	rr := patch("application/merge-patch+json", `{"price":12.5}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("merge patch: expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var got models.Product
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode product: %v", err)
+	}
+	if got.Price != 12.5 || got.Name != "Patched Product" || got.Version != 2 {
+		t.Fatalf("unexpected merge-patched product: %+v", got)
+	}
+
+	events, err := auditEvents(created.UUID)
+	if err != nil || len(events) == 0 {
+		t.Fatalf("find audit event: %v", err)
+	}
+	if audit := events[len(events)-1]; audit.Action != "UPDATE" || audit.Message != "patched product (changed: price)" {
+		t.Fatalf("unexpected audit message %q", audit.Message)
+	}
+
+	// ---------- JSON PATCH ----------
+	rr = patch("application/json-patch+json", `[{"op":"test","path":"/price","value":12.5},{"op":"replace","path":"/name","value":"Renamed Product"}]`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("json patch: expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = patch("application/json-patch+json", `[{"op":"test","path":"/price","value":1}]`)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("failed test op: expected 409 got %d", rr.Code)
+	}
+
+	// ---------- REJECTIONS ----------
+	for _, tc := range []struct {
+		name, contentType, body string
+		status                  int
+	}{
+		{"read-only field", "application/merge-patch+json", `{"version":99}`, http.StatusUnprocessableEntity},
+		{"unknown field", "application/merge-patch+json", `{"colour":"red"}`, http.StatusBadRequest},
+		{"invalid result", "application/merge-patch+json", `{"price":0}`, http.StatusUnprocessableEntity},
+		{"bad patch document", "application/json-patch+json", `{"op":"replace"}`, http.StatusBadRequest},
+		{"media type", "text/plain", `price=1`, http.StatusUnsupportedMediaType},
+	} {
+		rr := patch(tc.contentType, tc.body)
+		if rr.Code != tc.status {
+			t.Errorf("%s: expected %d got %d: %s", tc.name, tc.status, rr.Code, rr.Body.String())
+		}
+	}
+
+	stored, err := testStore.Products.Get(context.Background(), created.UUID)
+	if err != nil {
+		t.Fatalf("get product: %v", err)
+	}
+	if stored.Name != "Renamed Product" || stored.Price != 12.5 {
+		t.Fatalf("rejected patches must not change the product: %+v", stored)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-demo/pkg/problem"
+)
+
+func TestValidationProblemListsFields(t *testing.T) {
+	mux := newTestMux()
+
+	req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(`{"name":"","price":-1}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Fatalf("expected %s got %q", problem.ContentType, ct)
+	}
+
+	var p problem.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if p.Type != problem.TypeValidation || p.Instance != "/products" {
+		t.Fatalf("unexpected problem: %+v", p)
+	}
+
+	fields := map[string]problem.FieldError{}
+	for _, fe := range p.Errors {
+		fields[fe.Field] = fe
+	}
+	if fe, ok := fields["name"]; !ok || fe.Tag != "required" {
+		t.Errorf("expected required error on name, got %+v", p.Errors)
+	}
+	if fe, ok := fields["price"]; !ok || fe.Tag != "gt" || fe.Param != "0" || fe.Message == "" {
+		t.Errorf("expected gt=0 error on price, got %+v", p.Errors)
+	}
+}
+
+func TestDecodeProblems(t *testing.T) {
+	mux := newTestMux()
+
+	for _, tc := range []struct {
+		name        string
+		body        string
+		contentType string
+		status      int
+	}{
+		{"malformed", `{"name":`, "application/json", http.StatusBadRequest},
+		{"empty", ``, "application/json", http.StatusBadRequest},
+		{"unknown field", `{"name":"A","role":"B","admin":true}`, "application/json", http.StatusBadRequest},
+		{"wrong type", `{"name":1,"role":"B"}`, "application/json", http.StatusBadRequest},
+		{"trailing data", `{"name":"A","role":"B"} {}`, "application/json", http.StatusBadRequest},
+		{"too large", `{"name":"` + strings.Repeat("a", 2<<20) + `","role":"B"}`, "application/json", http.StatusRequestEntityTooLarge},
+		{"content type", `name=A`, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tc.status {
+				t.Fatalf("expected %d got %d: %s", tc.status, rr.Code, rr.Body.String())
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Fatalf("expected %s got %q", problem.ContentType, ct)
+			}
+		})
+	}
+}
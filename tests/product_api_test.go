@@ -2,10 +2,11 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 
-	"go-demo/handlers"
+	"go-demo/models"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -13,13 +14,20 @@ import (
 
 var _ = Describe("Product API", func() {
 
+	// target creates a product to update or delete and returns its item URL.
+	target := func() string {
+		created, err := testStore.Products.Create(context.Background(), models.Product{Name: "Ginkgo Target Product", Price: 9.99})
+		Expect(err).NotTo(HaveOccurred())
+		return "/products?id=" + created.UUID
+	}
+
 	It("should create a product", func() {
 		body := []byte(`{"name":"Ginkgo Product","price":199.99}`)
 		req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
-		handlers.ProductHandler(rr, req)
+		testServer.ProductHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusCreated))
 	})
@@ -28,27 +36,27 @@ var _ = Describe("Product API", func() {
 		req := httptest.NewRequest(http.MethodGet, "/products", nil)
 		rr := httptest.NewRecorder()
 
-		handlers.ProductHandler(rr, req)
+		testServer.ProductHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusOK))
 	})
 
 	It("should update a product", func() {
 		body := []byte(`{"name":"Updated Product","price":299.99}`)
-		req := httptest.NewRequest(http.MethodPut, "/products?id=1", bytes.NewBuffer(body))
+		req := httptest.NewRequest(http.MethodPut, target(), bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
-		handlers.ProductHandler(rr, req)
+		testServer.ProductHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusOK))
 	})
 
 	It("should delete a product", func() {
-		req := httptest.NewRequest(http.MethodDelete, "/products?id=1", nil)
+		req := httptest.NewRequest(http.MethodDelete, target(), nil)
 		rr := httptest.NewRecorder()
 
-		handlers.ProductHandler(rr, req)
+		testServer.ProductHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusNoContent))
 	})
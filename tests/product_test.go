@@ -2,11 +2,12 @@ package tests
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
-	"go-demo/handlers"
+	"go-demo/models"
 )
 
 func TestProductCRUD(t *testing.T) {
@@ -17,17 +18,29 @@ func TestProductCRUD(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	handlers.ProductHandler(rr, req)
+	testServer.ProductHandler(rr, req)
 
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("CREATE product failed, expected 201 got %d", rr.Code)
 	}
 
+	var created models.Product
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode created product: %v", err)
+	}
+	if created.UUID == "" || created.CreatedAt.IsZero() {
+		t.Fatalf("expected persisted product in response, got %+v", created)
+	}
+	target := "/products?id=" + created.UUID
+	if loc := rr.Header().Get("Location"); loc != "/products/"+created.UUID {
+		t.Fatalf("unexpected Location header %q", loc)
+	}
+
 	// ---------- READ ----------
 	req = httptest.NewRequest(http.MethodGet, "/products", nil)
 	rr = httptest.NewRecorder()
 
-	handlers.ProductHandler(rr, req)
+	testServer.ProductHandler(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("GET products failed, expected 200 got %d", rr.Code)
@@ -35,21 +48,21 @@ func TestProductCRUD(t *testing.T) {
 
 	// ---------- UPDATE ----------
 	updateBody := []byte(`{"name":"Updated Product","price":250.00}`)
-	req = httptest.NewRequest(http.MethodPut, "/products?id=1", bytes.NewBuffer(updateBody))
+	req = httptest.NewRequest(http.MethodPut, target, bytes.NewBuffer(updateBody))
 	req.Header.Set("Content-Type", "application/json")
 
 	rr = httptest.NewRecorder()
-	handlers.ProductHandler(rr, req)
+	testServer.ProductHandler(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("UPDATE product failed, expected 200 got %d", rr.Code)
 	}
 
 	// ---------- DELETE ----------
-	req = httptest.NewRequest(http.MethodDelete, "/products?id=1", nil)
+	req = httptest.NewRequest(http.MethodDelete, target, nil)
 	rr = httptest.NewRecorder()
 
-	handlers.ProductHandler(rr, req)
+	testServer.ProductHandler(rr, req)
 
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("DELETE product failed, expected 204 got %d", rr.Code)
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go-demo/models"
+)
+
+func TestUserItemRoutes(t *testing.T) {
+	mux := newTestMux()
+
+	created, err := testStore.Users.Create(context.Background(), models.User{Name: "Route User", Role: "Tester"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	id := created.UUID
+
+	// ---------- GET ONE ----------
+	req := httptest.NewRequest(http.MethodGet, "/users/"+id, nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET user failed, expected 200 got %d", rr.Code)
+	}
+	var got models.User
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode user: %v", err)
+	}
+	if got.UUID != id {
+		t.Fatalf("expected user %s, got %s", id, got.UUID)
+	}
+
+	// ---------- PATCH ----------
+	req = httptest.NewRequest(http.MethodPatch, "/users/"+id, bytes.NewBufferString(`{"role":"Admin"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PATCH user failed, expected 200 got %d", rr.Code)
+	}
+	patched, err := testStore.Users.Get(context.Background(), got.UUID)
+	if err != nil {
+		t.Fatalf("get patched user: %v", err)
+	}
+	if patched.Role != "Admin" || patched.Name != "Route User" {
+		t.Fatalf("unexpected patched user: %+v", patched)
+	}
+	var body models.User
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Role != "Admin" {
+		t.Fatalf("expected patched user in response, got %s", rr.Body.String())
+	}
+
+	// ---------- DELETE ----------
+	req = httptest.NewRequest(http.MethodDelete, "/users/"+id, nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("DELETE user failed, expected 204 got %d", rr.Code)
+	}
+
+	// ---------- GET MISSING ----------
+	req = httptest.NewRequest(http.MethodGet, "/users/"+id, nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("GET deleted user, expected 404 got %d", rr.Code)
+	}
+}
+
+func TestProductItemRoutes(t *testing.T) {
+	mux := newTestMux()
+
+	created, err := testStore.Products.Create(context.Background(), models.Product{Name: "Route Product", Price: 10})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	id := created.UUID
+
+	req := httptest.NewRequest(http.MethodGet, "/products/"+id, nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET product failed, expected 200 got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/products/not-a-number", nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("GET product with bad id, expected 400 got %d", rr.Code)
+	}
+}
+
+func TestMissingItemsReturnNotFound(t *testing.T) {
+	mux := newTestMux()
+
+	for _, tc := range []struct {
+		method, target, body string
+	}{
+		{http.MethodPut, "/users/00000000-0000-4000-8000-000000000000", `{"name":"Nobody","role":"None"}`},
+		{http.MethodDelete, "/users/00000000-0000-4000-8000-000000000000", ""},
+		{http.MethodPut, "/products/00000000-0000-4000-8000-000000000000", `{"name":"Nothing","price":1}`},
+		{http.MethodDelete, "/products/00000000-0000-4000-8000-000000000000", ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404 got %d", tc.method, tc.target, rr.Code)
+		}
+	}
+}
+
+// TestItemRoutesCanonicaliseUUIDs checks the other spellings uuid.Parse
+// accepts address the same product rather than missing it.
+func TestItemRoutesCanonicaliseUUIDs(t *testing.T) {
+	mux := newTestMux()
+
+	created, err := testStore.Products.Create(context.Background(), models.Product{Name: "Spelled Product", Price: 3})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	id := created.UUID
+
+	for _, form := range []string{
+		strings.ToUpper(id),
+		"urn:uuid:" + id,
+		"{" + id + "}",
+		strings.ReplaceAll(id, "-", ""),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/products/"+url.PathEscape(form), nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("GET product as %q: expected 200 got %d", form, rr.Code)
+			continue
+		}
+		var got models.Product
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || got.UUID != id {
+			t.Errorf("GET product as %q: expected %s, got %s", form, id, rr.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/products/"+url.PathEscape("{"+id), nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("GET product with an unbalanced brace: expected 400 got %d", rr.Code)
+	}
+}
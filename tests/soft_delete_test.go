@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-demo/models"
+	"go-demo/repositories"
+)
+
+func TestProductTrashLifecycle(t *testing.T) {
+	mux := newTestMux()
+
+	created, err := testStore.Products.Create(context.Background(), models.Product{Name: "Trashed Product", Price: 3})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	item := "/products/" + created.UUID
+
+	do := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBuffer(nil))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodDelete, item, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected 204 got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, item, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("GET deleted: expected 404 got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, item+"?include_deleted=true", ""); rr.Code != http.StatusOK {
+		t.Fatalf("GET include_deleted: expected 200 got %d", rr.Code)
+	}
+
+	page, err := testStore.Products.List(context.Background(), repositories.ProductQuery{NameContains: "Trashed Product", IncludeDeleted: true, ListParams: repositories.ListParams{Sort: "-created_at", Limit: 1}})
+	if err != nil || len(page.Items) != 1 || page.Items[0].UUID != created.UUID || !page.Items[0].DeletedAt.Valid {
+		t.Fatalf("expected deleted product in include_deleted listing, got %+v (err %v)", page.Items, err)
+	}
+
+	// ---------- RESTORE ----------
+	if rr := do(http.MethodPost, item+"/restore", ""); rr.Code != http.StatusOK {
+		t.Fatalf("restore: expected 200 got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, item+"/restore", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("restore live product: expected 409 got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, item, ""); rr.Code != http.StatusOK {
+		t.Fatalf("GET restored: expected 200 got %d", rr.Code)
+	}
+
+	// ---------- PURGE ----------
+	purge := "/admin/products/" + created.UUID
+	if rr := do(http.MethodDelete, purge, ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("purge with admin disabled: expected 404 got %d", rr.Code)
+	}
+
+	testServer.AdminToken = "test-admin-token"
+	defer func() { testServer.AdminToken = "" }()
+
+	if rr := do(http.MethodDelete, purge, "wrong"); rr.Code != http.StatusForbidden {
+		t.Fatalf("purge with wrong token: expected 403 got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, purge, "test-admin-token"); rr.Code != http.StatusConflict {
+		t.Fatalf("purge live product: expected 409 got %d", rr.Code)
+	}
+	do(http.MethodDelete, item, "")
+	if rr := do(http.MethodDelete, purge, "test-admin-token"); rr.Code != http.StatusNoContent {
+		t.Fatalf("purge: expected 204 got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, item+"?include_deleted=true", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("GET purged: expected 404 got %d", rr.Code)
+	}
+}
+
+func TestCleanupPurgesExpiredTrash(t *testing.T) {
+	requireDatabase(t)
+	recent, err := testStore.Users.Create(context.Background(), models.User{Name: "Recently Trashed User", Role: "Tester"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	old, err := testStore.Users.Create(context.Background(), models.User{Name: "Long Trashed User", Role: "Tester"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	for _, id := range []string{recent.UUID, old.UUID} {
+		if err := testStore.Users.Delete(context.Background(), id, 0); err != nil {
+			t.Fatalf("delete user %s: %v", id, err)
+		}
+	}
+	if err := testDB.Model(&models.User{}).Unscoped().Where("uuid = ?", old.UUID).Update("deleted_at", time.Now().Add(-10*24*time.Hour)).Error; err != nil {
+		t.Fatalf("age deleted user: %v", err)
+	}
+
+	testWorkers.RunPurgeOnce(7 * 24 * time.Hour)
+
+	if _, err := testStore.Users.GetIncludingDeleted(context.Background(), recent.UUID); err != nil {
+		t.Errorf("expected recently deleted user to stay in the trash: %v", err)
+	}
+	if _, err := testStore.Users.GetIncludingDeleted(context.Background(), old.UUID); err == nil {
+		t.Error("expected user deleted 10 days ago to be purged")
+	}
+}
@@ -0,0 +1,336 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-demo/models"
+	uuidpkg "go-demo/pkg/uuid"
+	"go-demo/repositories"
+)
+
+// The same cases run against every Store backend, so the in-memory store
+// stays a faithful stand-in for the database.
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storeConformance(t, repositories.NewMemory().Store())
+}
+
+func TestGormStoreConformance(t *testing.T) {
+	requireDatabase(t)
+	storeConformance(t, testStore)
+}
+
+func storeConformance(t *testing.T, store repositories.Store) {
+	ctx := context.Background()
+	// token keeps names unique, so filters only see this run's rows on a
+	// shared database.
+	token := strings.ReplaceAll(uuidpkg.New()[:8], "-", "")
+
+	t.Run("create generates identity", func(t *testing.T) {
+		before := time.Now().Add(-time.Second)
+		u, err := store.Users.Create(ctx, models.User{Name: "Generated " + token, Role: "Tester"})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if u.ID == 0 || !uuidpkg.Valid(u.UUID) || u.Version != 1 || u.CreatedAt.Before(before) {
+			t.Fatalf("expected generated id, uuid, version and created_at, got %+v", u)
+		}
+		got, err := store.Users.Get(ctx, u.UUID)
+		if err != nil || got.ID != u.ID || got.CreatedAt.Sub(u.CreatedAt).Abs() > time.Millisecond {
+			t.Fatalf("get: expected %+v, got %+v (%v)", u, got, err)
+		}
+
+		next, err := store.Users.Create(ctx, models.User{Name: "Generated " + token, Role: "Tester"})
+		if err != nil || next.ID <= u.ID || next.UUID == u.UUID {
+			t.Fatalf("expected a later id and a fresh uuid, got %+v (%v)", next, err)
+		}
+
+		id := uuidpkg.New()
+		p, err := store.Products.Create(ctx, models.Product{UUID: id, Name: "Supplied " + token, Price: 1})
+		if err != nil || p.UUID != id {
+			t.Fatalf("expected client uuid %s kept, got %+v (%v)", id, p, err)
+		}
+		if _, err := store.Products.Create(ctx, models.Product{UUID: id, Name: "Duplicate " + token, Price: 1}); !errors.Is(err, repositories.ErrConflict) {
+			t.Fatalf("duplicate uuid: expected ErrConflict, got %v", err)
+		}
+		if _, err := store.Users.Get(ctx, uuidpkg.New()); !errors.Is(err, repositories.ErrNotFound) {
+			t.Fatalf("unknown uuid: expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("upsert", func(t *testing.T) {
+		id := uuidpkg.New()
+		p, created, err := store.Products.Upsert(ctx, models.Product{UUID: id, Name: "Upserted " + token, Price: 1})
+		if err != nil || !created || p.Version != 1 {
+			t.Fatalf("first upsert: expected created v1, got %+v created=%v (%v)", p, created, err)
+		}
+		p, created, err = store.Products.Upsert(ctx, models.Product{UUID: id, Name: "Upserted again " + token, Price: 2})
+		if err != nil || created || p.Version != 2 || p.Price != 2 || p.Name != "Upserted again "+token {
+			t.Fatalf("second upsert: expected updated v2, got %+v created=%v (%v)", p, created, err)
+		}
+
+		if err := store.Products.Delete(ctx, id, 0); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, _, err := store.Products.Upsert(ctx, models.Product{UUID: id, Name: "Upserted " + token, Price: 3}); !errors.Is(err, repositories.ErrConflict) {
+			t.Fatalf("upsert over deleted: expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("update and patch check versions", func(t *testing.T) {
+		u, err := store.Users.Create(ctx, models.User{Name: "Versioned " + token, Role: "Tester"})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		u, err = store.Users.Update(ctx, u.UUID, models.User{Name: "Versioned " + token, Role: "Manager"}, 1)
+		if err != nil || u.Version != 2 || u.Role != "Manager" {
+			t.Fatalf("update: expected v2 Manager, got %+v (%v)", u, err)
+		}
+		if _, err := store.Users.Update(ctx, u.UUID, u, 1); !errors.Is(err, repositories.ErrVersionMismatch) {
+			t.Fatalf("stale update: expected ErrVersionMismatch, got %v", err)
+		}
+		if _, err := store.Users.Update(ctx, uuidpkg.New(), u, 0); !errors.Is(err, repositories.ErrNotFound) {
+			t.Fatalf("update unknown: expected ErrNotFound, got %v", err)
+		}
+
+		u, err = store.Users.Patch(ctx, u.UUID, models.User{Name: "Ignored", Role: "Admin"}, []string{"role", "version", "nonsense"}, 2)
+		if err != nil || u.Version != 3 || u.Role != "Admin" || u.Name != "Versioned "+token {
+			t.Fatalf("patch: expected only role written at v3, got %+v (%v)", u, err)
+		}
+		same, err := store.Users.Patch(ctx, u.UUID, models.User{}, []string{"uuid"}, 0)
+		if err != nil || same.Version != 3 {
+			t.Fatalf("empty patch: expected stored user unchanged, got %+v (%v)", same, err)
+		}
+		if err := store.Users.Delete(ctx, u.UUID, 2); !errors.Is(err, repositories.ErrVersionMismatch) {
+			t.Fatalf("stale delete: expected ErrVersionMismatch, got %v", err)
+		}
+	})
+
+	t.Run("trash lifecycle", func(t *testing.T) {
+		u, err := store.Users.Create(ctx, models.User{Name: "Trashed " + token, Role: "Tester"})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if err := store.Users.Purge(ctx, u.UUID); !errors.Is(err, repositories.ErrNotInTrash) {
+			t.Fatalf("purge live user: expected ErrNotInTrash, got %v", err)
+		}
+		if err := store.Users.Delete(ctx, u.UUID, 1); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, err := store.Users.Get(ctx, u.UUID); !errors.Is(err, repositories.ErrNotFound) {
+			t.Fatalf("get deleted: expected ErrNotFound, got %v", err)
+		}
+		deleted, err := store.Users.GetIncludingDeleted(ctx, u.UUID)
+		if err != nil || !deleted.DeletedAt.Valid {
+			t.Fatalf("get including deleted: got %+v (%v)", deleted, err)
+		}
+
+		restored, err := store.Users.Restore(ctx, u.UUID)
+		if err != nil || restored.DeletedAt.Valid || restored.Version != 2 {
+			t.Fatalf("restore: expected live v2, got %+v (%v)", restored, err)
+		}
+		if _, err := store.Users.Restore(ctx, u.UUID); !errors.Is(err, repositories.ErrNotInTrash) {
+			t.Fatalf("restore live user: expected ErrNotInTrash, got %v", err)
+		}
+
+		if err := store.Users.Delete(ctx, u.UUID, 0); err != nil {
+			t.Fatalf("delete again: %v", err)
+		}
+		if n, err := store.Users.PurgeDeletedBefore(ctx, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("purge before an hour ago: %v", err)
+		} else if _, err := store.Users.GetIncludingDeleted(ctx, u.UUID); err != nil {
+			t.Fatalf("user deleted just now must survive a purge of older rows (purged %d): %v", n, err)
+		}
+		if err := store.Users.Purge(ctx, u.UUID); err != nil {
+			t.Fatalf("purge: %v", err)
+		}
+		if _, err := store.Users.GetIncludingDeleted(ctx, u.UUID); !errors.Is(err, repositories.ErrNotFound) {
+			t.Fatalf("get purged: expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("list filters and pages", func(t *testing.T) {
+		name := "Listed " + token
+		var deleted models.Product
+		for i, price := range []float64{5, 1, 3, 3, 9} {
+			p, err := store.Products.Create(ctx, models.Product{Name: name, Price: price})
+			if err != nil {
+				t.Fatalf("create %d: %v", i, err)
+			}
+			deleted = p
+		}
+		if err := store.Products.Delete(ctx, deleted.UUID, 0); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+
+		// Walk the list two at a time; each page must resume where the
+		// previous one ended and count every match.
+		min := 2.0
+		q := repositories.ProductQuery{NameContains: strings.ToLower(name), PriceGTE: &min, ListParams: repositories.ListParams{Sort: "-price", Limit: 2, IncludeTotal: true}}
+		var prices []float64
+		for pages := 0; ; pages++ {
+			page, err := store.Products.List(ctx, q)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if page.Total == nil || *page.Total != 3 {
+				t.Fatalf("expected total 3, got %v", page.Total)
+			}
+			for _, p := range page.Items {
+				prices = append(prices, p.Price)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			if pages > 3 {
+				t.Fatal("cursor does not advance")
+			}
+			q.Cursor = page.NextCursor
+		}
+		if len(prices) != 3 || prices[0] != 5 || prices[1] != 3 || prices[2] != 3 {
+			t.Fatalf("expected prices [5 3 3] without the deleted product, got %v", prices)
+		}
+
+		q = repositories.ProductQuery{NameContains: name, IncludeDeleted: true, ListParams: repositories.ListParams{Sort: "price"}}
+		page, err := store.Products.List(ctx, q)
+		if err != nil || len(page.Items) != 5 {
+			t.Fatalf("include deleted: expected 5 products, got %d (%v)", len(page.Items), err)
+		}
+		if last := page.Items[4]; last.UUID != deleted.UUID || !last.DeletedAt.Valid {
+			t.Fatalf("expected the deleted product last by price, got %+v", last)
+		}
+
+		if _, err := store.Products.List(ctx, repositories.ProductQuery{ListParams: repositories.ListParams{Sort: "role"}}); !errors.Is(err, repositories.ErrInvalidSort) {
+			t.Fatalf("unknown sort: expected ErrInvalidSort, got %v", err)
+		}
+		if _, err := store.Products.List(ctx, repositories.ProductQuery{ListParams: repositories.ListParams{Cursor: "not-a-cursor"}}); !errors.Is(err, repositories.ErrInvalidCursor) {
+			t.Fatalf("bad cursor: expected ErrInvalidCursor, got %v", err)
+		}
+
+		if _, err := store.Users.Create(ctx, models.User{Name: "Role " + token, Role: "Auditor" + token}); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users, err := store.Users.List(ctx, repositories.UserQuery{Role: "Auditor" + token, CreatedAfter: time.Now().Add(-time.Hour)})
+		if err != nil || len(users.Items) != 1 || users.Items[0].Name != "Role "+token {
+			t.Fatalf("role filter: got %+v (%v)", users.Items, err)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		name := "Streamed " + token
+		for _, role := range []string{"c", "a", "b"} {
+			if _, err := store.Users.Create(ctx, models.User{Name: name, Role: role}); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+		var roles []string
+		err := store.Users.Stream(ctx, repositories.UserQuery{NameContains: name, ListParams: repositories.ListParams{Sort: "role"}}, func(u models.User) error {
+			roles = append(roles, u.Role)
+			return nil
+		})
+		if err != nil || strings.Join(roles, "") != "abc" {
+			t.Fatalf("expected roles streamed in order abc, got %v (%v)", roles, err)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = store.Users.Stream(ctx, repositories.UserQuery{NameContains: name}, func(models.User) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Fatalf("expected the callback error to stop the stream after one row, got %v after %d", err, calls)
+		}
+	})
+
+	t.Run("unit of work", func(t *testing.T) {
+		committed, rolledBack, inner := uuidpkg.New(), uuidpkg.New(), uuidpkg.New()
+		err := store.Tx.Transaction(ctx, func(ctx context.Context) error {
+			if _, err := store.Users.Create(ctx, models.User{UUID: committed, Name: "Committed " + token, Role: "Tester"}); err != nil {
+				return err
+			}
+			err := store.Tx.Transaction(ctx, func(ctx context.Context) error {
+				if _, err := store.Users.Create(ctx, models.User{UUID: inner, Name: "Inner " + token, Role: "Tester"}); err != nil {
+					return err
+				}
+				return errors.New("inner failed")
+			})
+			if err == nil {
+				t.Error("expected the inner unit of work to fail")
+			}
+			if err := store.Audit.Publish(ctx, models.AuditLog{Action: "CREATE", Entity: "user", EntityUUID: committed, Message: "created", Timestamp: time.Now()}); err != nil {
+				return err
+			}
+			return store.Outbox.Enqueue(ctx, "WELCOME_EMAIL", committed, "{}")
+		})
+		if err != nil {
+			t.Fatalf("outer unit of work: %v", err)
+		}
+		if _, err := store.Users.Get(ctx, committed); err != nil {
+			t.Fatalf("committed user: %v", err)
+		}
+		if _, err := store.Users.Get(ctx, inner); !errors.Is(err, repositories.ErrNotFound) {
+			t.Fatalf("inner user must roll back with its savepoint, got %v", err)
+		}
+
+		boom := errors.New("boom")
+		err = store.Tx.Transaction(ctx, func(ctx context.Context) error {
+			if _, err := store.Users.Create(ctx, models.User{UUID: rolledBack, Name: "Rolled back " + token, Role: "Tester"}); err != nil {
+				return err
+			}
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			t.Fatalf("expected the unit of work to return its error, got %v", err)
+		}
+		if _, err := store.Users.GetIncludingDeleted(ctx, rolledBack); !errors.Is(err, repositories.ErrNotFound) {
+			t.Fatalf("rolled back user: expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		existing, err := store.Products.Create(ctx, models.Product{Name: "Imported " + token, Price: 1})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := store.Products.Create(ctx, models.Product{Name: "Twin " + token, Price: 1}); err != nil {
+				t.Fatalf("create twin: %v", err)
+			}
+		}
+		rows := []repositories.ImportRow{
+			{Line: 1, Product: models.Product{Name: "Imported " + token, Price: 2}},
+			{Line: 2, Product: models.Product{UUID: uuidpkg.New(), Name: "Fresh " + token, Price: 3}},
+			{Line: 3, Product: models.Product{Name: "Twin " + token, Price: 4}},
+		}
+
+		results, err := store.Products.Import(ctx, rows, true)
+		if err != nil || len(results) != 3 {
+			t.Fatalf("dry run: got %+v (%v)", results, err)
+		}
+		if got, _ := store.Products.Get(ctx, existing.UUID); got.Price != 1 {
+			t.Fatalf("dry run must not write, price is %v", got.Price)
+		}
+
+		results, err = store.Products.Import(ctx, rows, false)
+		if err != nil || len(results) != 3 {
+			t.Fatalf("import: got %+v (%v)", results, err)
+		}
+		want := []string{repositories.ImportUpdated, repositories.ImportCreated, repositories.ImportRejected}
+		for i, res := range results {
+			if res.Status != want[i] || res.Line != i+1 {
+				t.Fatalf("row %d: expected %s, got %+v", i+1, want[i], res)
+			}
+		}
+		if results[0].UUID != existing.UUID || results[1].UUID != strings.ToLower(rows[1].Product.UUID) {
+			t.Fatalf("unexpected uuids in %+v", results)
+		}
+		if got, _ := store.Products.Get(ctx, existing.UUID); got.Price != 2 || got.Version != 2 {
+			t.Fatalf("expected imported update at v2 price 2, got %+v", got)
+		}
+	})
+}
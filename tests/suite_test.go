@@ -1,22 +1,14 @@
 package tests
 
 import (
-	"os"
 	"testing"
 
-	"go-demo/config"
-	"go-demo/database"
-
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
 
+// TestAPIs runs the Ginkgo specs against the database TestMain connected.
 func TestAPIs(t *testing.T) {
-	os.Setenv("APP_ENV", "test")
-
-	config.LoadEnv()
-	database.Connect()
-
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "API Integration Test Suite")
 }
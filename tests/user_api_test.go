@@ -2,10 +2,11 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 
-	"go-demo/handlers"
+	"go-demo/models"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -13,13 +14,20 @@ import (
 
 var _ = Describe("User API", func() {
 
+	// target creates a user to update or delete and returns its item URL.
+	target := func() string {
+		created, err := testStore.Users.Create(context.Background(), models.User{Name: "Ginkgo Target User", Role: "Tester"})
+		Expect(err).NotTo(HaveOccurred())
+		return "/users?id=" + created.UUID
+	}
+
 	It("should create a user", func() {
 		body := []byte(`{"name":"Ginkgo User","role":"Tester"}`)
 		req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
-		handlers.UserHandler(rr, req)
+		testServer.UserHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusCreated))
 	})
@@ -30,7 +38,7 @@ var _ = Describe("User API", func() {
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
-		handlers.UserHandler(rr, req)
+		testServer.UserHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusCreated))
 		// The notification is enqueued asynchronously and processed by the worker
@@ -41,27 +49,27 @@ var _ = Describe("User API", func() {
 		req := httptest.NewRequest(http.MethodGet, "/users", nil)
 		rr := httptest.NewRecorder()
 
-		handlers.UserHandler(rr, req)
+		testServer.UserHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusOK))
 	})
 
 	It("should update a user", func() {
 		body := []byte(`{"name":"Updated User","role":"Admin"}`)
-		req := httptest.NewRequest(http.MethodPut, "/users?id=1", bytes.NewBuffer(body))
+		req := httptest.NewRequest(http.MethodPut, target(), bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 
 		rr := httptest.NewRecorder()
-		handlers.UserHandler(rr, req)
+		testServer.UserHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusOK))
 	})
 
 	It("should delete a user", func() {
-		req := httptest.NewRequest(http.MethodDelete, "/users?id=1", nil)
+		req := httptest.NewRequest(http.MethodDelete, target(), nil)
 		rr := httptest.NewRecorder()
 
-		handlers.UserHandler(rr, req)
+		testServer.UserHandler(rr, req)
 
 		Expect(rr.Code).To(Equal(http.StatusNoContent))
 	})
@@ -2,11 +2,12 @@ package tests
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
-	"go-demo/handlers"
+	"go-demo/models"
 )
 
 func TestUserCRUD(t *testing.T) {
@@ -17,17 +18,34 @@ func TestUserCRUD(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	handlers.UserHandler(rr, req)
+	testServer.UserHandler(rr, req)
 
 	if rr.Code != http.StatusCreated {
 		t.Fatalf("CREATE user failed, expected 201 got %d", rr.Code)
 	}
 
+	var created models.User
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode created user: %v", err)
+	}
+	if created.UUID == "" || created.CreatedAt.IsZero() {
+		t.Fatalf("expected persisted user in response, got %+v", created)
+	}
+	target := "/users?id=" + created.UUID
+	if loc := rr.Header().Get("Location"); loc != "/users/"+created.UUID {
+		t.Fatalf("unexpected Location header %q", loc)
+	}
+
+	events, err := auditEvents(created.UUID)
+	if err != nil || len(events) == 0 || events[0].Action != "CREATE" || events[0].Entity != "user" {
+		t.Fatalf("expected CREATE audit event for user %s, got %+v (%v)", created.UUID, events, err)
+	}
+
 	// ---------- READ ----------
 	req = httptest.NewRequest(http.MethodGet, "/users", nil)
 	rr = httptest.NewRecorder()
 
-	handlers.UserHandler(rr, req)
+	testServer.UserHandler(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("GET users failed, expected 200 got %d", rr.Code)
@@ -35,21 +53,21 @@ func TestUserCRUD(t *testing.T) {
 
 	// ---------- UPDATE ----------
 	updateBody := []byte(`{"name":"Updated User","role":"Manager"}`)
-	req = httptest.NewRequest(http.MethodPut, "/users?id=1", bytes.NewBuffer(updateBody))
+	req = httptest.NewRequest(http.MethodPut, target, bytes.NewBuffer(updateBody))
 	req.Header.Set("Content-Type", "application/json")
 
 	rr = httptest.NewRecorder()
-	handlers.UserHandler(rr, req)
+	testServer.UserHandler(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("UPDATE user failed, expected 200 got %d", rr.Code)
 	}
 
 	// ---------- DELETE ----------
-	req = httptest.NewRequest(http.MethodDelete, "/users?id=1", nil)
+	req = httptest.NewRequest(http.MethodDelete, target, nil)
 	rr = httptest.NewRecorder()
 
-	handlers.UserHandler(rr, req)
+	testServer.UserHandler(rr, req)
 
 	if rr.Code != http.StatusNoContent {
 		t.Fatalf("DELETE user failed, expected 204 got %d", rr.Code)
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-demo/models"
+	uuidpkg "go-demo/pkg/uuid"
+)
+
+func TestProductUpsertByClientUUID(t *testing.T) {
+	mux := newTestMux()
+	id := uuidpkg.New()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/products", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(`{"uuid":"` + id + `","name":"Client UUID Product","price":1}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("first upsert: expected 201 got %d: %s", rr.Code, rr.Body.String())
+	}
+	if loc := rr.Header().Get("Location"); loc != "/products/"+id {
+		t.Fatalf("unexpected Location header %q", loc)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("decode product: %v", err)
+	}
+	if _, ok := raw["id"]; ok {
+		t.Fatalf("serial id must not be exposed: %s", rr.Body.String())
+	}
+
+	rr = post(`{"uuid":"` + id + `","name":"Client UUID Product","price":2}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("second upsert: expected 200 got %d: %s", rr.Code, rr.Body.String())
+	}
+	var got models.Product
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode product: %v", err)
+	}
+	if got.UUID != id || got.Price != 2 || got.Version != 2 {
+		t.Fatalf("unexpected upserted product: %+v", got)
+	}
+
+	rr = post(`{"uuid":"not-a-uuid","name":"Bad UUID Product","price":1}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid uuid: expected 422 got %d", rr.Code)
+	}
+}
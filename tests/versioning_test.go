@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-demo/handlers"
+	"go-demo/pkg/apiversion"
+)
+
+func newVersionedMux(t *testing.T) (*http.ServeMux, *apiversion.Router) {
+	t.Helper()
+	v1 := newTestMux()
+
+	v2 := http.NewServeMux()
+	v2.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v2 " + r.PathValue("id")))
+	})
+
+	mux := http.NewServeMux()
+	router := apiversion.NewRouter(mux)
+	router.Mount(apiversion.Version{
+		Name:       "v1",
+		Handler:    v1,
+		Deprecated: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:     time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Successor:  "v2",
+	})
+	router.Mount(apiversion.Version{Name: "v2", Handler: v2})
+	return mux, router
+}
+
+func TestVersionedRoutesAndDeprecationHeaders(t *testing.T) {
+	mux, router := newVersionedMux(t)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v2/users/abc", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "v2 abc" {
+		t.Fatalf("expected v2 handler, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Deprecation") != "" || rr.Header().Get("Sunset") != "" {
+		t.Fatal("current version must not be marked deprecated")
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected v1 spec, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Deprecation"); got != "@1767225600" {
+		t.Fatalf("unexpected Deprecation header %q", got)
+	}
+	if got := rr.Header().Get("Sunset"); got != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Fatalf("unexpected Sunset header %q", got)
+	}
+	if got := rr.Header().Get("Link"); !strings.Contains(got, `</v2/>; rel="successor-version"`) {
+		t.Fatalf("unexpected Link header %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unversioned path must not be served without a root mount, got %d", rr.Code)
+	}
+
+	stats := map[string]apiversion.Stats{}
+	for _, s := range router.Stats() {
+		stats[s.Version] = s
+	}
+	if stats["v1"].Requests != 1 || stats["v2"].Requests != 1 {
+		t.Fatalf("unexpected request counts: %+v", stats)
+	}
+	if !stats["v1"].Deprecated || stats["v1"].LastRequest == nil {
+		t.Fatalf("unexpected v1 stats: %+v", stats["v1"])
+	}
+	if s := stats["v1"]; s.Since.IsZero() || s.Since.After(*s.LastRequest) {
+		t.Fatalf("expected the counters' start reported with them: %+v", s)
+	}
+}
+
+func TestVersionedLocationHeader(t *testing.T) {
+	mux, _ := newVersionedMux(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", bytes.NewBufferString(`{"name":"Versioned","role":"Tester"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", rr.Code)
+	}
+	var created struct {
+		UUID string `json:"uuid"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if got, want := rr.Header().Get("Location"), "/v1/users/"+created.UUID; got != want {
+		t.Fatalf("expected Location %q, got %q", want, got)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/users/not-a-uuid", nil))
+	var p struct {
+		Instance string `json:"instance"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	if p.Instance != "/v1/users/not-a-uuid" {
+		t.Fatalf("expected versioned problem instance, got %q", p.Instance)
+	}
+
+	if doc := handlers.Spec(); len(doc.Servers) != 1 || doc.Servers[0].URL != "/v1" {
+		t.Fatalf("expected spec server /v1, got %+v", doc.Servers)
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-demo/config"
+	"go-demo/models"
+	"go-demo/repositories"
+	"go-demo/worker"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// newReloadWorkers returns Workers on a database of their own, so the
+// pollers the test binary runs on the shared one cannot interfere.
+func newReloadWorkers(t *testing.T, cfg config.Worker) (*worker.Workers, *gorm.DB) {
+	t.Helper()
+	db, m := newMigrationDB(t)
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	return worker.New(db, repositories.NewGormStore(db), cfg), db
+}
+
+func TestWorkersReloadPollIntervalImmediately(t *testing.T) {
+	cfg := testConfig.Worker
+	cfg.PollInterval = time.Hour
+	w, db := newReloadWorkers(t, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		w.Wait()
+	}()
+	w.StartAuditWorker(ctx)
+	// let the poller start on the hour interval
+	time.Sleep(50 * time.Millisecond)
+
+	event := worker.NewEvent("created", "user", "", "reload test")
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatalf("create audit log: %v", err)
+	}
+
+	// Without the change reaching the poller, its first batch is an hour off
+	cfg.PollInterval = 10 * time.Millisecond
+	w.Reconfigure(cfg)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var got models.AuditLog
+		if err := db.First(&got, event.ID).Error; err != nil {
+			t.Fatalf("load audit log: %v", err)
+		}
+		if got.ProcessedAt != nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("audit log not processed on the reloaded poll interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWorkersReloadRetentionForNextCleanup(t *testing.T) {
+	cfg := testConfig.Worker
+	cfg.Retention = 24 * time.Hour
+	w, db := newReloadWorkers(t, cfg)
+	c := cron.New()
+	w.RegisterCleanupWorker(c)
+	runCleanup := func() { c.Entries()[0].Job.Run() }
+
+	old := models.User{Name: "Two Hours Old", Role: "Tester", CreatedAt: time.Now().Add(-2 * time.Hour)}
+	if err := db.Create(&old).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	runCleanup()
+	if err := db.First(&models.User{}, old.ID).Error; err != nil {
+		t.Fatalf("expected the user kept under a 24h retention: %v", err)
+	}
+
+	cfg.Retention = time.Hour
+	w.Reconfigure(cfg)
+	runCleanup()
+	if err := db.First(&models.User{}, old.ID).Error; err == nil {
+		t.Error("expected the user trashed by the cleanup after the retention was reloaded to 1h")
+	}
+}
+
+func TestWorkersReloadCleanupSchedule(t *testing.T) {
+	cfg := testConfig.Worker
+	cfg.CleanupSchedule = "@every 1m"
+	w, _ := newReloadWorkers(t, cfg)
+	c := cron.New()
+	w.RegisterCleanupWorker(c)
+	before := c.Entries()
+	if len(before) != 1 {
+		t.Fatalf("expected one cleanup entry, got %d", len(before))
+	}
+
+	cfg.CleanupSchedule = "@every 5m"
+	w.Reconfigure(cfg)
+	after := c.Entries()
+	if len(after) != 1 {
+		t.Fatalf("expected the old cleanup entry replaced, got %d entries", len(after))
+	}
+	if after[0].ID == before[0].ID {
+		t.Error("expected the cleanup registered as a new entry")
+	}
+	if s, ok := after[0].Schedule.(cron.ConstantDelaySchedule); !ok || s.Delay != 5*time.Minute {
+		t.Errorf("expected the cleanup on the reloaded 5m schedule, got %#v", after[0].Schedule)
+	}
+
+	// an unchanged schedule keeps the entry
+	cfg.Retention *= 2
+	w.Reconfigure(cfg)
+	if again := c.Entries(); len(again) != 1 || again[0].ID != after[0].ID {
+		t.Errorf("expected the cleanup entry kept when the schedule is unchanged, got %+v", again)
+	}
+}
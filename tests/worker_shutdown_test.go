@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-demo/models"
+	"go-demo/repositories"
+	"go-demo/worker"
+)
+
+// TestWorkersStopWhenCancelled checks the pollers return once their
+// context is cancelled, so the worker binary can close the database after
+// them.
+func TestWorkersStopWhenCancelled(t *testing.T) {
+	requireDatabase(t)
+	w := worker.New(testDB, testStore, testConfig.Worker)
+	ctx, cancel := context.WithCancel(context.Background())
+	w.StartAuditWorker(ctx)
+	w.StartNotificationWorker(ctx)
+
+	// let at least one batch run before stopping
+	time.Sleep(testConfig.Worker.PollInterval + 100*time.Millisecond)
+	cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pollers did not stop after their context was cancelled")
+	}
+}
+
+// TestNotificationWorkerRequeuesStaleMessages checks a message left
+// PROCESSING by a worker killed mid-batch is sent on the next start.
+func TestNotificationWorkerRequeuesStaleMessages(t *testing.T) {
+	db, m := newMigrationDB(t)
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	stale := models.NotificationOutbox{EventType: "WELCOME_EMAIL", Payload: `{"recipient":"a@example.com","message":"hi"}`, Status: "PROCESSING"}
+	if err := db.Create(&stale).Error; err != nil {
+		t.Fatalf("create outbox message: %v", err)
+	}
+
+	cfg := testConfig.Worker
+	cfg.PollInterval = 10 * time.Millisecond
+	w := worker.New(db, repositories.NewGormStore(db), cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		w.Wait()
+	}()
+	w.StartNotificationWorker(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var got models.NotificationOutbox
+		if err := db.First(&got, stale.ID).Error; err != nil {
+			t.Fatalf("load outbox message: %v", err)
+		}
+		if got.Status == "DONE" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stale message not processed, still %s", got.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,58 +1,79 @@
 package worker
 
 import (
+	"context"
 	"time"
 
-	"go-demo/database"
 	"go-demo/models"
 	"go-demo/pkg/logger"
 
 	"github.com/robfig/cron/v3"
 )
 
-// DefaultRetention is the period after which records are considered expired
-// and removed by the cleanup worker (e.g. 7 days).
-const DefaultRetention = 1 * time.Minute
+// RegisterCleanupWorker registers the cleanup job with the provided cron scheduler.
+func (w *Workers) RegisterCleanupWorker(c *cron.Cron) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cron = c
+	if err := w.addCleanup(); err != nil {
+		logger.Log.Fatal().Err(err).Msg("failed to register cleanup worker")
+	}
 
-// CleanupSchedule is the cron expression for how often the worker runs.
-const CleanupSchedule = "@every 1m"
+	cfg := w.settings()
+	logger.Log.Info().
+		Str("schedule", cfg.CleanupSchedule).
+		Dur("retention", cfg.Retention).
+		Dur("trash_grace", cfg.TrashGrace).
+		Msg("cleanup worker registered")
+}
 
-// RegisterCleanupWorker registers the cleanup job with the provided cron scheduler.
-func RegisterCleanupWorker(c *cron.Cron) {
-	_, err := c.AddFunc(CleanupSchedule, func() {
+// rescheduleCleanup replaces the registered cleanup job with one on the
+// current schedule.
+func (w *Workers) rescheduleCleanup() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cron == nil {
+		return
+	}
+	w.cron.Remove(w.cleanupEntry)
+	if err := w.addCleanup(); err != nil {
+		logger.Log.Error().Err(err).Msg("failed to reschedule cleanup worker")
+		return
+	}
+	logger.Log.Info().Str("schedule", w.settings().CleanupSchedule).Msg("cleanup worker rescheduled")
+}
+
+// addCleanup adds the cleanup job to w.cron; w.mu must be held.
+func (w *Workers) addCleanup() error {
+	id, err := w.cron.AddFunc(w.settings().CleanupSchedule, func() {
 		// Wrapper to handle panic recovery per job run
 		defer func() {
 			if r := recover(); r != nil {
 				logger.Log.Error().Interface("panic", r).Msg("cleanup worker panic recovered")
 			}
 		}()
-		runCleanup(DefaultRetention)
+		// read per run, so a reload applies from the next one
+		cfg := w.settings()
+		w.runCleanup(cfg.Retention)
+		w.runPurge(cfg.TrashGrace)
 	})
-
 	if err != nil {
-		logger.Log.Fatal().Err(err).Msg("failed to register cleanup worker")
+		return err
 	}
-
-	logger.Log.Info().
-		Str("schedule", CleanupSchedule).
-		Dur("retention", DefaultRetention).
-		Msg("cleanup worker registered")
+	w.cleanupEntry = id
+	return nil
 }
 
-// runCleanup executes the cleanup logic once: deletes users and products
-// where created_at is older than the given retention. Logs deleted counts.
-func runCleanup(retention time.Duration) {
+// runCleanup executes the cleanup logic once: soft-deletes users and products
+// where created_at is older than the given retention, moving them to the
+// trash. Logs deleted counts.
+func (w *Workers) runCleanup(retention time.Duration) {
 	logger.Log.Info().Msg("cleanup job executing") // Log when job starts
 
-	if database.GormDB == nil {
-		logger.Log.Warn().Msg("cleanup skipped: no DB connection")
-		return
-	}
-
 	cutoff := time.Now().Add(-retention)
 
 	// Delete old users
-	resultUsers := database.GormDB.Unscoped().Delete(
+	resultUsers := w.db.Delete(
 		&models.User{},
 		"created_at < ?",
 		cutoff,
@@ -64,7 +85,7 @@ func runCleanup(retention time.Duration) {
 	usersDeleted := resultUsers.RowsAffected
 
 	// Delete old products
-	resultProducts := database.GormDB.Unscoped().Delete(
+	resultProducts := w.db.Delete(
 		&models.Product{},
 		"created_at < ?",
 		cutoff,
@@ -86,8 +107,38 @@ func runCleanup(retention time.Duration) {
 	}
 }
 
+// runPurge permanently removes users and products that were soft-deleted
+// more than grace ago. Logs purged counts.
+func (w *Workers) runPurge(grace time.Duration) {
+	ctx := context.Background()
+	cutoff := time.Now().Add(-grace)
+
+	usersPurged, err := w.store.Users.PurgeDeletedBefore(ctx, cutoff)
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("purge users failed")
+		return
+	}
+
+	productsPurged, err := w.store.Products.PurgeDeletedBefore(ctx, cutoff)
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("purge products failed")
+		return
+	}
+
+	logger.Log.Info().
+		Int64("users_purged", usersPurged).
+		Int64("products_purged", productsPurged).
+		Msg("trash purge completed")
+}
+
 // RunCleanupOnce runs the cleanup logic once with the given retention.
 // Used by tests to exercise cleanup without waiting for the cron.
-func RunCleanupOnce(retention time.Duration) {
-	runCleanup(retention)
+func (w *Workers) RunCleanupOnce(retention time.Duration) {
+	w.runCleanup(retention)
+}
+
+// RunPurgeOnce permanently removes records soft-deleted more than grace ago.
+// Used by tests to exercise the purge without waiting for the cron.
+func (w *Workers) RunPurgeOnce(grace time.Duration) {
+	w.runPurge(grace)
 }
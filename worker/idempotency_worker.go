@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go-demo/pkg/logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+// RegisterIdempotencyCleanup registers the expired idempotency key cleanup
+// with the provided cron scheduler.
+func (w *Workers) RegisterIdempotencyCleanup(c *cron.Cron) {
+	_, err := c.AddFunc(w.settings().IdempotencyCleanupSchedule, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Log.Error().Interface("panic", r).Msg("idempotency cleanup panic recovered")
+			}
+		}()
+		w.RunIdempotencyCleanupOnce(time.Now())
+	})
+
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("failed to register idempotency cleanup")
+	}
+
+	logger.Log.Info().
+		Str("schedule", w.settings().IdempotencyCleanupSchedule).
+		Msg("idempotency cleanup registered")
+}
+
+// RunIdempotencyCleanupOnce deletes idempotency keys that expired before now.
+func (w *Workers) RunIdempotencyCleanupOnce(now time.Time) {
+	deleted, err := w.store.Idempotency.DeleteExpired(context.Background(), now)
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("idempotency cleanup failed")
+		return
+	}
+	logger.Log.Info().Int64("idempotency_keys_deleted", deleted).Msg("idempotency cleanup completed")
+}
@@ -1,36 +1,45 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
-	"go-demo/database"
 	"go-demo/models"
 	"go-demo/pkg/logger"
 )
 
-// StartNotificationWorker initializes the notification worker that polls the notification outbox.
-func StartNotificationWorker() {
-	go func() {
-		ticker := time.NewTicker(1 * time.Second) // Poll every second
-		defer ticker.Stop()
-
-		for range ticker.C {
-			processNotificationOutbox()
-		}
-	}()
+// StartNotificationWorker initializes the notification worker that polls the
+// notification outbox until ctx is cancelled. Messages a previous run left
+// PROCESSING are requeued first.
+func (w *Workers) StartNotificationWorker(ctx context.Context) {
+	w.requeueStaleNotifications()
+	w.poll(ctx, w.processNotificationOutbox)
 	logger.Log.Info().Msg("notification worker started (outbox polling)")
 }
 
-func processNotificationOutbox() {
-	if database.GormDB == nil {
+// requeueStaleNotifications puts messages stuck in PROCESSING, by a worker
+// killed mid-batch, back to PENDING. Such a message may have been sent
+// already, so it can go out twice; that beats never. One worker process
+// runs per database, so no other one is processing them.
+func (w *Workers) requeueStaleNotifications() {
+	result := w.db.Model(&models.NotificationOutbox{}).
+		Where("status = ?", "PROCESSING").
+		Update("status", "PENDING")
+	if result.Error != nil {
+		logger.Log.Error().Err(result.Error).Msg("failed to requeue stale notification outbox messages")
 		return
 	}
+	if result.RowsAffected > 0 {
+		logger.Log.Warn().Int64("requeued", result.RowsAffected).Msg("requeued notification outbox messages left processing")
+	}
+}
 
+func (w *Workers) processNotificationOutbox() {
 	// Fetch up to 100 pending messages from outbox
 	var messages []models.NotificationOutbox
 	// Find messages where Status is PENDING
-	if err := database.GormDB.Where("status = ?", "PENDING").Limit(100).Order("created_at asc").Find(&messages).Error; err != nil {
+	if err := w.db.Where("status = ?", "PENDING").Limit(100).Order("created_at asc").Find(&messages).Error; err != nil {
 		logger.Log.Error().Err(err).Msg("failed to fetch notification outbox messages")
 		return
 	}
@@ -40,11 +49,11 @@ func processNotificationOutbox() {
 	}
 
 	for _, msg := range messages {
-		processSingleMessage(msg)
+		w.processSingleMessage(msg)
 	}
 }
 
-func processSingleMessage(msg models.NotificationOutbox) {
+func (w *Workers) processSingleMessage(msg models.NotificationOutbox) {
 	// Log: PICKED
 	logger.Log.Info().
 		Uint("job_id", msg.ID).
@@ -65,13 +74,13 @@ func processSingleMessage(msg models.NotificationOutbox) {
 			// Mark as FAILED
 			msg.Status = "FAILED"
 			msg.Error = "Panic recovered"
-			database.GormDB.Save(&msg)
+			w.db.Save(&msg)
 		}
 	}()
 
 	// Mark as PROCESSING
 	msg.Status = "PROCESSING"
-	if err := database.GormDB.Save(&msg).Error; err != nil {
+	if err := w.db.Save(&msg).Error; err != nil {
 		logger.Log.Error().
 			Err(err).
 			Uint("job_id", msg.ID).
@@ -106,7 +115,7 @@ func processSingleMessage(msg models.NotificationOutbox) {
 		
 		msg.Status = "FAILED"
 		msg.Error = err.Error()
-		database.GormDB.Save(&msg)
+		w.db.Save(&msg)
 		return
 	}
 
@@ -125,7 +134,7 @@ func processSingleMessage(msg models.NotificationOutbox) {
 	now := time.Now()
 	msg.Status = "DONE"
 	msg.ProcessedAt = &now
-	if err := database.GormDB.Save(&msg).Error; err != nil {
+	if err := w.db.Save(&msg).Error; err != nil {
 		logger.Log.Error().
 			Err(err).
 			Uint("job_id", msg.ID).
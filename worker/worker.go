@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-demo/config"
+	"go-demo/pkg/logger"
+	"go-demo/repositories"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// Workers runs the background jobs against one database: the audit and
+// notification pollers and the cron cleanups.
+type Workers struct {
+	db    *gorm.DB
+	store repositories.Store
+	cfg   atomic.Pointer[config.Worker]
+
+	pollers sync.WaitGroup
+
+	mu           sync.Mutex // guards the fields below
+	cron         *cron.Cron
+	cleanupEntry cron.EntryID
+	// intervalChanged is closed, and replaced, when a reload changes the
+	// poll interval, waking every poller to reset its ticker.
+	intervalChanged chan struct{}
+}
+
+// New returns Workers polling db and cleaning up through store, on the
+// intervals and schedules in cfg.
+func New(db *gorm.DB, store repositories.Store, cfg config.Worker) *Workers {
+	w := &Workers{db: db, store: store, intervalChanged: make(chan struct{})}
+	w.cfg.Store(&cfg)
+	return w
+}
+
+// settings returns the current configuration.
+func (w *Workers) settings() config.Worker {
+	return *w.cfg.Load()
+}
+
+// Reconfigure applies cfg to running workers: pollers switch to the new
+// interval at once, cleanup runs use the new retention and a changed
+// cleanup schedule replaces the registered one.
+func (w *Workers) Reconfigure(cfg config.Worker) {
+	old := w.settings()
+	w.cfg.Store(&cfg)
+	if cfg.PollInterval != old.PollInterval {
+		w.mu.Lock()
+		close(w.intervalChanged)
+		w.intervalChanged = make(chan struct{})
+		w.mu.Unlock()
+	}
+	if cfg.CleanupSchedule != old.CleanupSchedule {
+		w.rescheduleCleanup()
+	}
+	logger.Log.Info().
+		Dur("poll_interval", cfg.PollInterval).
+		Str("cleanup_schedule", cfg.CleanupSchedule).
+		Dur("retention", cfg.Retention).
+		Msg("workers reconfigured")
+}
+
+// poll runs batch every poll interval until ctx is cancelled. A batch in
+// progress when ctx is cancelled runs to completion, so rows are never
+// left half-processed.
+func (w *Workers) poll(ctx context.Context, batch func()) {
+	w.pollers.Add(1)
+	go func() {
+		defer w.pollers.Done()
+		// watched before the interval is read, so no change is missed
+		changed := w.watchInterval()
+		interval := w.settings().PollInterval
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+				changed = w.watchInterval()
+				if next := w.settings().PollInterval; next != interval {
+					interval = next
+					ticker.Reset(interval)
+				}
+			case <-ticker.C:
+				batch()
+			}
+		}
+	}()
+}
+
+// watchInterval returns a channel closed on the next change of the poll
+// interval.
+func (w *Workers) watchInterval() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.intervalChanged
+}
+
+// Wait blocks until every poller started on w has stopped after its
+// context was cancelled.
+func (w *Workers) Wait() {
+	w.pollers.Wait()
+}